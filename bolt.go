@@ -1,194 +1,1272 @@
 package kv
 
 import (
-	"fmt"
-	"path/filepath"
+	"context"
+	"encoding/binary"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/boltdb/bolt"
 )
 
-// boltKv implements KV interface using boltdb as backend kv store.
-type boltKv struct {
+var (
+	_ KVContext       = (*boltKv)(nil)
+	_ Txn             = (*boltKv)(nil)
+	_ Versioned       = (*boltKv)(nil)
+	_ Conditional     = (*boltKv)(nil)
+	_ Iterator        = (*boltKv)(nil)
+	_ Pager           = (*boltKv)(nil)
+	_ Scanner         = (*boltKv)(nil)
+	_ ValueEnumerator = (*boltKv)(nil)
+	_ Lister          = (*boltKv)(nil)
+	_ Stater          = (*boltKv)(nil)
+	_ Expirer         = (*boltKv)(nil)
+	_ Watcher         = (*boltKv)(nil)
+	_ Copier          = (*boltKv)(nil)
+	_ Expirer         = (*boltTx)(nil)
+	_ revisionTx      = (*boltTx)(nil)
+	_ Store           = (*boltStore)(nil)
+)
+
+// boltStore implements Store over a bolt database file, keeping every
+// namespace in a top level bucket.
+type boltStore struct {
 	// mu is used to lock update operations on database.
 	mu sync.Mutex
-	// nameSpace is the top level bucket name.
-	nameSpace string
 	// db is the database object for which database file is opened.
 	db *bolt.DB
+	// now tells the current time when expiring keys.
+	now func() time.Time
+	// done is closed to stop the janitor.
+	done chan struct{}
+	// hubs holds watchers per namespace and is guarded by mu.
+	hubs map[string]*hub
+}
+
+// boltKv implements KV interface using boltdb as backend kv store.
+type boltKv struct {
+	*boltStore
+	// nameSpace is the top level bucket name.
+	nameSpace string
+}
+
+// boltTx implements Tx over a bolt transaction.
+type boltTx struct {
+	ctx       context.Context
+	nameSpace string
+	t         *bolt.Tx
+	// now is the time at which the transaction started, which decides
+	// which keys have expired.
+	now time.Time
+	// events are recorded if watch is set and published on commit.
+	watch  bool
+	events []Event
 }
 
 // newBoltKv provides a new instance of KV with bolt db as backend.
-func newBoltKv(dbFile, nameSpace string) (*boltKv, func() error, error) {
-	kv := new(boltKv)
-	kv.nameSpace = nameSpace
+func newBoltKv(dbFile, nameSpace string, opts ...Option) (*boltKv, func() error, error) {
+	s, f, err := newBoltStore(dbFile, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kv, err := s.namespace(nameSpace)
+	if err != nil {
+		_ = f()
+		return nil, nil, err
+	}
+
+	return kv, f, nil
+}
+
+// newBoltStore provides a new instance of Store with bolt db as backend.
+func newBoltStore(dbFile string, opts ...Option) (*boltStore, func() error, error) {
+	o := newOptions(opts)
+	s := new(boltStore)
+	s.now = o.now
+	s.done = make(chan struct{})
+	s.hubs = make(map[string]*hub)
 	var err error
-	kv.db, err = bolt.Open(dbFile, 0666, nil)
+	s.db, err = bolt.Open(dbFile, 0666, nil)
 	if err != nil {
 		return nil, nil, err
 	}
-	f := func() error { return kv.db.Close() }
 
-	if err := kv.db.Update(func(t *bolt.Tx) error {
-		_, err := t.CreateBucketIfNotExists([]byte(nameSpace))
+	var once sync.Once
+	f := func() error {
+		once.Do(func() {
+			close(s.done)
+			s.mu.Lock()
+			for _, h := range s.hubs {
+				h.close()
+			}
+			s.mu.Unlock()
+		})
+		return s.db.Close()
+	}
+
+	go s.janitor(o.ticker(o.janitorInterval))
+
+	return s, f, nil
+}
+
+// Namespace returns a KV over namespace ns, creating it if it does not exist.
+func (s *boltStore) Namespace(ns string) (KV, error) {
+	return s.namespace(ns)
+}
+
+func (s *boltStore) namespace(ns string) (*boltKv, error) {
+	if err := checkNamespace(ns); err != nil {
+		return nil, namespaceError("open", ns, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.db.Update(func(t *bolt.Tx) error {
+		_, err := t.CreateBucketIfNotExists([]byte(ns))
 		return err
 	}); err != nil {
-		return nil, nil, err
+		return nil, namespaceError("open", ns, boltError(err))
 	}
-	return kv, f, nil
+
+	return &boltKv{boltStore: s, nameSpace: ns}, nil
 }
 
-// Set sets a value at a key.
-func (kv *boltKv) Set(key string, val []byte) error {
-	if len(key) == 0 || val == nil {
-		return fmt.Errorf("key can not be empty and val can not be nil")
+// hub returns watchers of namespace ns, which are shared by all boltKvs
+// over it. s.mu must be held.
+func (s *boltStore) hub(ns string) *hub {
+	h, ok := s.hubs[ns]
+	if !ok {
+		h = newHub()
+		s.hubs[ns] = h
 	}
 
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
+	return h
+}
+
+// closeHub ends watchers of namespace ns once it is dropped or renamed, so
+// that they are not fed by a namespace created again under its name. s.mu
+// must be held.
+func (s *boltStore) closeHub(ns string) {
+	if h, ok := s.hubs[ns]; ok {
+		h.close()
+		delete(s.hubs, ns)
+	}
+}
+
+// ListNamespaces lists namespaces in sorted order.
+func (s *boltStore) ListNamespaces() ([]string, error) {
+	var names []string
+	if err := s.db.View(func(t *bolt.Tx) error {
+		return t.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if !reservedBucket(name) {
+				names = append(names, string(name))
+			}
+			return nil
+		})
+	}); err != nil {
+		return nil, boltError(err)
+	}
+
+	return names, nil
+}
+
+// DropNamespace deletes a namespace along with all keys in it. boltKvs over
+// it fail with ErrNotFound until it is created again, and their watchers
+// are closed.
+func (s *boltStore) DropNamespace(ns string) error {
+	if err := checkNamespace(ns); err != nil {
+		return namespaceError("drop", ns, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.db.Update(func(t *bolt.Tx) error {
+		if err := t.DeleteBucket([]byte(ns)); err != nil {
+			return err
+		}
+
+		for _, name := range [][]byte{expiriesBucket(ns), revisionsBucket(ns)} {
+			if err := t.DeleteBucket(name); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		if err == bolt.ErrBucketNotFound {
+			err = ErrNotFound
+		}
+		return namespaceError("drop", ns, boltError(err))
+	}
+
+	s.closeHub(ns)
+	return nil
+}
+
+// RenameNamespace renames a namespace by copying its buckets within a single
+// transaction. boltKvs over the old name fail with ErrNotFound until it is
+// created again, and their watchers are closed.
+func (s *boltStore) RenameNamespace(old, new string) error {
+	if err := checkNamespace(old); err != nil {
+		return namespaceError("rename", old, err)
+	}
+
+	if err := checkNamespace(new); err != nil {
+		return namespaceError("rename", new, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.db.Update(func(t *bolt.Tx) error {
+		if t.Bucket([]byte(old)) == nil {
+			return namespaceError("rename", old, ErrNotFound)
+		}
+
+		if t.Bucket([]byte(new)) != nil {
+			return namespaceError("rename", new, ErrExists)
+		}
+
+		for _, names := range [][2][]byte{
+			{[]byte(old), []byte(new)},
+			{expiriesBucket(old), expiriesBucket(new)},
+			{revisionsBucket(old), revisionsBucket(new)},
+		} {
+			src := t.Bucket(names[0])
+			if src == nil {
+				continue
+			}
+
+			dst, err := t.CreateBucket(names[1])
+			if err != nil {
+				return err
+			}
+
+			if err := copyBucket(dst, src); err != nil {
+				return err
+			}
+
+			if err := t.DeleteBucket(names[0]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return namespaceError("rename", old, boltError(err))
+	}
+
+	s.closeHub(old)
+	return nil
+}
 
-	return kv.db.Update(func(t *bolt.Tx) error {
-		bucketList := strings.Split(filepath.Join(kv.nameSpace, key), "/")
-		b := t.Bucket([]byte(bucketList[0]))
-		bucketList = bucketList[1:]
-		if len(bucketList) > 0 {
-			var err error
-			for i := 0; i < len(bucketList)-1; i++ {
-				b, err = b.CreateBucketIfNotExists([]byte(bucketList[i]))
-				if err != nil {
-					return err
-				}
-			}
-			return b.Put([]byte(bucketList[len(bucketList)-1]), val)
-		} else {
-			return b.Put([]byte(key), val)
+// copyBucket copies all values and nested buckets of src into dst.
+func copyBucket(dst, src *bolt.Bucket) error {
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
 		}
+
+		b, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+
+		return copyBucket(b, src.Bucket(k))
 	})
 }
 
+// expiriesBucket returns the name of the top level bucket holding expiry
+// times of keys in nameSpace, keyed by their path within nameSpace. Names
+// of such reserved buckets start with a zero byte.
+func expiriesBucket(nameSpace string) []byte {
+	return []byte(expiriesPrefix + nameSpace)
+}
+
+// expiriesPrefix prefixes names of buckets returned by expiriesBucket.
+const expiriesPrefix = "\x00ttl/"
+
+// revisionsBucket returns the name of the top level bucket holding the
+// revision every leaf in nameSpace was last written at, keyed by its path.
+func revisionsBucket(nameSpace string) []byte {
+	return []byte(revisionsPrefix + nameSpace)
+}
+
+// revisionsPrefix prefixes names of buckets returned by revisionsBucket.
+const revisionsPrefix = "\x00rev/"
+
+// sequenceBucket names the top level bucket whose sequence numbers revisions
+// across all namespaces, so that revisions never repeat, not even when a
+// namespace is dropped and created again.
+var sequenceBucket = []byte("\x00seq")
+
+// reservedBucket tells whether a top level bucket is not a namespace.
+func reservedBucket(name []byte) bool {
+	return len(name) > 0 && name[0] == 0
+}
+
+// boltError maps errors returned by bolt to errors defined in this package.
+func boltError(err error) error {
+	switch err {
+	case bolt.ErrDatabaseNotOpen:
+		return ErrClosed
+	case bolt.ErrIncompatibleValue:
+		return ErrPathIsValue
+	case bolt.ErrTxNotWritable:
+		return ErrReadOnly
+	default:
+		return err
+	}
+}
+
+// view runs f in a read-only bolt transaction.
+func (kv *boltKv) view(ctx context.Context, f func(tx *boltTx) error) error {
+	return boltError(kv.db.View(func(t *bolt.Tx) error {
+		return f(&boltTx{ctx: ctx, nameSpace: kv.nameSpace, t: t, now: kv.now()})
+	}))
+}
+
+// update runs f in a read-write bolt transaction.
+func (kv *boltKv) update(ctx context.Context, f func(tx *boltTx) error) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	var tx *boltTx
+	if err := kv.db.Update(func(t *bolt.Tx) error {
+		tx = &boltTx{ctx: ctx, nameSpace: kv.nameSpace, t: t, now: kv.now(), watch: kv.hub(kv.nameSpace).active()}
+		return f(tx)
+	}); err != nil {
+		return boltError(err)
+	}
+
+	kv.hub(kv.nameSpace).publish(tx.events)
+	return nil
+}
+
+// Update runs f in a single read-write bolt transaction.
+func (kv *boltKv) Update(f func(tx Tx) error) error {
+	return kv.update(context.Background(), func(tx *boltTx) error { return f(tx) })
+}
+
+// View runs f in a single read-only bolt transaction.
+func (kv *boltKv) View(f func(tx Tx) error) error {
+	return kv.view(context.Background(), func(tx *boltTx) error { return f(tx) })
+}
+
+// Set sets a value at a key.
+func (kv *boltKv) Set(key string, val []byte) error {
+	return kv.SetContext(context.Background(), key, val)
+}
+
+// SetContext sets a value at a key honoring ctx.
+func (kv *boltKv) SetContext(ctx context.Context, key string, val []byte) error {
+	return keyError("set", key, kv.update(ctx, func(tx *boltTx) error {
+		return tx.Set(key, val)
+	}))
+}
+
 // Get gets a value from a key.
 func (kv *boltKv) Get(key string) ([]byte, error) {
-	if len(key) == 0 {
-		return nil, fmt.Errorf("key can not be empty")
-	}
+	return kv.GetContext(context.Background(), key)
+}
 
+// GetContext gets a value from a key honoring ctx.
+func (kv *boltKv) GetContext(ctx context.Context, key string) ([]byte, error) {
 	var val []byte
-	err := kv.db.View(func(t *bolt.Tx) error {
-		bucketList := strings.Split(filepath.Join(kv.nameSpace, key), "/")
-		b := t.Bucket([]byte(bucketList[0]))
-		bucketList = bucketList[1:]
-		if len(bucketList) > 0 {
-			for i := 0; i < len(bucketList)-1; i++ {
-				val := b.Get([]byte(bucketList[i]))
-				if val != nil {
-					return fmt.Errorf(bucketList[i],
-						"does not point at a bucket, it points to a value")
-				}
-				b = b.Bucket([]byte(bucketList[i]))
-				if b == nil {
-					return fmt.Errorf("bucket does not exist:%s",
-						filepath.Join(bucketList[:i+1]...))
-				}
-			}
-			val = b.Get([]byte(bucketList[len(bucketList)-1]))
-		} else {
-			val = b.Get([]byte(key))
+	err := kv.view(ctx, func(tx *boltTx) error {
+		var err error
+		val, err = tx.Get(key)
+		return err
+	})
+
+	return val, keyError("get", key, err)
+}
+
+// Delete delets a key.
+func (kv *boltKv) Delete(key string) error {
+	return kv.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext deletes a key honoring ctx.
+func (kv *boltKv) DeleteContext(ctx context.Context, key string) error {
+	return keyError("delete", key, kv.update(ctx, func(tx *boltTx) error {
+		return tx.Delete(key)
+	}))
+}
+
+// Enumerate lists all keys under a key.
+func (kv *boltKv) Enumerate(key string) ([]string, error) {
+	return kv.EnumerateContext(context.Background(), key)
+}
+
+// EnumerateContext lists all keys under a key honoring ctx. The whole tree is
+// walked within a single read transaction.
+func (kv *boltKv) EnumerateContext(ctx context.Context, key string) ([]string, error) {
+	var list []string
+	err := kv.view(ctx, func(tx *boltTx) error {
+		var err error
+		list, err = tx.Enumerate(key)
+		return err
+	})
+
+	return list, keyError("enumerate", key, err)
+}
+
+// EnumerateValues lists all keys under a key along with their values
+// in a single walk.
+func (kv *boltKv) EnumerateValues(key string) ([]Pair, error) {
+	s := &scanCollector{values: true}
+	if err := kv.view(context.Background(), func(tx *boltTx) error {
+		return tx.walk(key, s.add)
+	}); err != nil {
+		return nil, keyError("enumerate", key, err)
+	}
+
+	return s.pairs, nil
+}
+
+// List lists the immediate children of a bucket.
+func (kv *boltKv) List(prefix string) ([]Entry, error) {
+	var entries []Entry
+	if err := kv.view(context.Background(), func(tx *boltTx) error {
+		b, keys, err := tx.bucketAt(prefix)
+		if err != nil {
+			return err
+		}
+
+		entries, err = listCursor(tx.ctx, tx.cursor(b, keys), prefix)
+		return err
+	}); err != nil {
+		return nil, keyError("list", prefix, err)
+	}
+
+	return entries, nil
+}
+
+// Stat describes a key.
+func (kv *boltKv) Stat(key string) (Info, error) {
+	var info Info
+	err := kv.view(context.Background(), func(tx *boltTx) error {
+		keys, err := splitKey(key)
+		if err != nil {
+			return err
 		}
+
+		if len(keys) == 0 {
+			return ErrEmptyKey
+		}
+
+		b, err := tx.bucket(keys[:len(keys)-1])
+		if err != nil {
+			return err
+		}
+
+		last := []byte(keys[len(keys)-1])
+		if v := b.Get(last); v != nil {
+			if tx.expired(keys) {
+				return ErrNotFound
+			}
+			info.Size = len(v)
+			return nil
+		}
+
+		c := b.Bucket(last)
+		if c == nil {
+			return ErrNotFound
+		}
+
+		info.IsBucket = true
+		info.Children = countChildren(tx.cursor(c, keys))
 		return nil
 	})
 
-	// we have ensured during Set that value for any key cannot be nil.
-	// A nil value means either key points to a bucket or key does not exist.
-	// Both such conditions should result in error for the use case of this pkg.
-	if val == nil {
-		err = fmt.Errorf("invalid key")
+	return statResult(key, info, err)
+}
+
+// Exists reports whether a key exists.
+func (kv *boltKv) Exists(key string) bool {
+	info, err := kv.Stat(key)
+	return err == nil && info.Exists
+}
+
+// SetWithTTL sets a value that expires after ttl. Expiry times are kept in a
+// reserved top level bucket, leaving values stored as they are.
+func (kv *boltKv) SetWithTTL(key string, val []byte, ttl time.Duration) error {
+	return keyError("set", key, kv.update(context.Background(), func(tx *boltTx) error {
+		return tx.SetWithTTL(key, val, ttl)
+	}))
+}
+
+// TTL returns the time left before a key expires.
+func (kv *boltKv) TTL(key string) (time.Duration, error) {
+	var ttl time.Duration
+	err := kv.view(context.Background(), func(tx *boltTx) error {
+		var err error
+		ttl, err = tx.TTL(key)
+		return err
+	})
+
+	return ttl, keyError("ttl", key, err)
+}
+
+// janitor reclaims expired keys on every tick until the db is closed,
+// calling stop then.
+func (s *boltStore) janitor(ticks <-chan time.Time, stop func()) {
+	defer stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticks:
+			_ = s.sweep()
+		}
+	}
+}
+
+// sweep reclaims expired keys of every namespace holding expiring keys.
+func (s *boltStore) sweep() error {
+	var names []string
+	if err := s.db.View(func(t *bolt.Tx) error {
+		return t.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if strings.HasPrefix(string(name), expiriesPrefix) {
+				names = append(names, strings.TrimPrefix(string(name), expiriesPrefix))
+			}
+			return nil
+		})
+	}); err != nil {
+		return boltError(err)
+	}
+
+	for _, ns := range names {
+		kv := &boltKv{boltStore: s, nameSpace: ns}
+		if err := kv.sweep(); err != nil {
+			return err
+		}
 	}
 
-	return val, err
+	return nil
 }
 
-// Delete delets a key.
-func (kv *boltKv) Delete(key string) error {
-	if len(key) == 0 {
-		return fmt.Errorf("key can not be empty")
+// sweep deletes expired leaves along with their expiry times. Expired keys
+// are looked up in a read transaction first so that no write transaction
+// is committed when there is nothing to reclaim.
+func (kv *boltKv) sweep() error {
+	var names []string
+	if err := kv.view(context.Background(), func(tx *boltTx) error {
+		b := tx.expiries()
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			if expired(decodeExpiry(v), tx.now) {
+				names = append(names, string(k))
+			}
+			return nil
+		})
+	}); err != nil || len(names) == 0 {
+		return err
 	}
 
+	return kv.update(context.Background(), func(tx *boltTx) error {
+		for _, name := range names {
+			keys := splitPath(name)
+			if !tx.expired(keys) {
+				// set again since it was looked up.
+				continue
+			}
+
+			b, err := tx.bucket(keys[:len(keys)-1])
+			if err != nil {
+				return err
+			}
+
+			if err := tx.deleteLeaf(b, keys); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Watch reports changes to keys under prefix made through this boltKv.
+func (kv *boltKv) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, keyError("watch", prefix, err)
+	}
+
+	// holding the writer lock makes sure no transaction is under way
+	// that started without recording events.
 	kv.mu.Lock()
 	defer kv.mu.Unlock()
 
-	err := kv.db.Update(func(t *bolt.Tx) error {
-		bucketList := strings.Split(filepath.Join(kv.nameSpace, key), "/")
-		b := t.Bucket([]byte(bucketList[0]))
-		bucketList = bucketList[1:]
-		if len(bucketList) > 0 {
-			for i := 0; i < len(bucketList)-1; i++ {
-				val := b.Get([]byte(bucketList[i]))
-				if val != nil {
-					return fmt.Errorf(bucketList[i],
-						"does not point at a bucket, it points to a value")
-				}
-				b = b.Bucket([]byte(bucketList[i]))
-				if b == nil {
-					return fmt.Errorf("bucket does not exist:%s",
-						filepath.Join(bucketList[:i+1]...))
-				}
-			}
-			key = bucketList[len(bucketList)-1]
-		}
-
-		val := b.Get([]byte(key))
-		if val == nil {
-			return b.DeleteBucket([]byte(key))
-		} else {
-			return b.Delete([]byte(key))
+	keys, err := splitKey(prefix)
+	if err != nil {
+		return nil, keyError("watch", prefix, err)
+	}
+
+	if err := kv.db.View(func(t *bolt.Tx) error {
+		if t.Bucket([]byte(kv.nameSpace)) == nil {
+			return ErrNotFound
 		}
+		return nil
+	}); err != nil {
+		return nil, keyError("watch", prefix, boltError(err))
+	}
+
+	return kv.hub(kv.nameSpace).watch(ctx, keys), nil
+}
+
+// GetWithVersion gets a value along with its version.
+func (kv *boltKv) GetWithVersion(key string) ([]byte, Version, error) {
+	var val []byte
+	var version Version
+	err := kv.view(context.Background(), func(tx *boltTx) error {
+		var err error
+		val, version, err = getWithVersion(tx, key)
+		return err
 	})
 
+	return val, version, keyError("get", key, err)
+}
+
+// SetIfVersion sets a value if key is still at version.
+func (kv *boltKv) SetIfVersion(key string, val []byte, version Version) error {
+	return keyError("set", key, kv.update(context.Background(), func(tx *boltTx) error {
+		return setIfVersion(tx, key, val, version)
+	}))
+}
+
+// DeleteIfVersion deletes a key if it is still at version.
+func (kv *boltKv) DeleteIfVersion(key string, version Version) error {
+	return keyError("delete", key, kv.update(context.Background(), func(tx *boltTx) error {
+		return deleteIfVersion(tx, key, version)
+	}))
+}
+
+// SetIfAbsent sets a value if key does not exist.
+func (kv *boltKv) SetIfAbsent(key string, val []byte) error {
+	return keyError("set", key, kv.update(context.Background(), func(tx *boltTx) error {
+		return setIfAbsent(tx, key, val)
+	}))
+}
+
+// Copy copies the leaf or bucket at src to dst in a single bolt transaction.
+func (kv *boltKv) Copy(src, dst string) error {
+	return keyError("copy", src, kv.update(context.Background(), func(tx *boltTx) error {
+		return copyKeys(tx, src, dst, false)
+	}))
+}
+
+// Move moves the leaf or bucket at src to dst in a single bolt transaction.
+func (kv *boltKv) Move(src, dst string) error {
+	return keyError("move", src, kv.update(context.Background(), func(tx *boltTx) error {
+		return copyKeys(tx, src, dst, true)
+	}))
+}
+
+// Replace sets a value if key exists.
+func (kv *boltKv) Replace(key string, val []byte) error {
+	return keyError("set", key, kv.update(context.Background(), func(tx *boltTx) error {
+		return replace(tx, key, val)
+	}))
+}
+
+// Iterate calls f for every key under prefix along with a copy of its value,
+// which bolt maps read-only, within a single read transaction.
+func (kv *boltKv) Iterate(ctx context.Context, prefix string, f func(key string, val []byte) error) error {
+	return keyError("iterate", prefix, kv.view(ctx, func(tx *boltTx) error {
+		return tx.walk(prefix, copyValues(f))
+	}))
+}
+
+// IterateKeys calls f for every key under prefix within a single read transaction.
+func (kv *boltKv) IterateKeys(ctx context.Context, prefix string, f func(key string) error) error {
+	return keyError("iterate", prefix, kv.view(ctx, func(tx *boltTx) error {
+		return tx.walk(prefix, func(key string, _ []byte) error { return f(key) })
+	}))
+}
+
+// EnumeratePage lists a page of keys under prefix in the order defined by
+// Key.Less, seeking cursors to the position token points to.
+func (kv *boltKv) EnumeratePage(prefix string, pageSize int, token string) ([]string, string, error) {
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	after, err := parsePageToken(token)
 	if err != nil {
-		return fmt.Errorf("key or bucket could not be deleted:%v", err)
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	p := &pageCollector{prefix: prefix, after: after, pageSize: pageSize}
+	if err := kv.view(context.Background(), func(tx *boltTx) error {
+		b, keys, err := tx.bucketAt(prefix)
+		if err != nil {
+			return err
+		}
+
+		return scanRange(tx.ctx, tx.cursor(b, keys), "", splitPath(after), nil, false, p.add)
+	}); err != nil && err != errStop {
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	keys, next := p.page()
+	return keys, next, nil
+}
+
+// Scan lists pairs under prefix within a range using cursor Seek and Prev.
+func (kv *boltKv) Scan(prefix, start, end string, reverse bool, limit int) ([]Pair, error) {
+	s := &scanCollector{prefix: prefix, limit: limit, values: true}
+	if err := kv.scan(prefix, start, end, reverse, s); err != nil {
+		return nil, err
+	}
+
+	return s.pairs, nil
+}
+
+// ScanKeys lists keys under prefix within a range using cursor Seek and Prev.
+func (kv *boltKv) ScanKeys(prefix, start, end string, reverse bool, limit int) ([]string, error) {
+	s := &scanCollector{prefix: prefix, limit: limit}
+	if err := kv.scan(prefix, start, end, reverse, s); err != nil {
+		return nil, err
+	}
+
+	return s.keys(), nil
+}
+
+func (kv *boltKv) scan(prefix, start, end string, reverse bool, s *scanCollector) error {
+	startKeys, endKeys, err := scanBounds(start, end)
+	if err != nil {
+		return keyError("scan", prefix, err)
+	}
+
+	if err := kv.view(context.Background(), func(tx *boltTx) error {
+		b, keys, err := tx.bucketAt(prefix)
+		if err != nil {
+			return err
+		}
+
+		return scanRange(tx.ctx, tx.cursor(b, keys), "", startKeys, endKeys, reverse, s.add)
+	}); err != nil && err != errStop {
+		return keyError("scan", prefix, err)
 	}
 
 	return nil
 }
 
-func (kv *boltKv) Enumerate(key string) ([]string, error) {
-	var list []string
-	err := kv.db.View(func(t *bolt.Tx) error {
-
-		// Get the bucket on which we would iterate for keys
-		bucketList := strings.Split(filepath.Join(kv.nameSpace, key), "/")
-		b := t.Bucket([]byte(bucketList[0]))
-		bucketList = bucketList[1:]
-		if len(bucketList) > 0 {
-			for i := 0; i < len(bucketList); i++ {
-				val := b.Get([]byte(bucketList[i]))
-				if val != nil {
-					return fmt.Errorf(bucketList[i],
-						"does not point at a bucket, it points to a value")
-				}
-				b = b.Bucket([]byte(bucketList[i]))
-				if b == nil {
-					return fmt.Errorf("bucket does not exist:%s",
-						filepath.Join(bucketList[:i+1]...))
-				}
+// bucketAt parses key and returns the bucket it points to along with the
+// parsed key.
+func (tx *boltTx) bucketAt(key string) (*bolt.Bucket, Key, error) {
+	keys, err := splitKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := tx.bucket(keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b, keys, nil
+}
+
+// bucket walks nested buckets along keys starting at the namespace bucket.
+func (tx *boltTx) bucket(keys []string) (*bolt.Bucket, error) {
+	b := tx.t.Bucket([]byte(tx.nameSpace))
+	if b == nil {
+		return nil, ErrNotFound
+	}
+
+	for i, key := range keys {
+		if b.Get([]byte(key)) != nil {
+			if tx.expired(keys[:i+1]) {
+				return nil, ErrNotFound
+			}
+			return nil, ErrPathIsValue
+		}
+		b = b.Bucket([]byte(key))
+		if b == nil {
+			return nil, ErrNotFound
+		}
+	}
+
+	return b, nil
+}
+
+// Set sets a value at a key creating buckets along the path.
+func (tx *boltTx) Set(key string, val []byte) error {
+	return tx.set(key, val, time.Time{})
+}
+
+// set sets a value that expires at expires, or never if expires is zero.
+// Expired leaves along the path are replaced by buckets.
+func (tx *boltTx) set(key string, val []byte, expires time.Time) error {
+	if err := tx.ctx.Err(); err != nil {
+		return keyError("set", key, err)
+	}
+
+	if !tx.t.Writable() {
+		return keyError("set", key, ErrReadOnly)
+	}
+
+	keys, err := splitKey(key)
+	if err != nil {
+		return keyError("set", key, err)
+	}
+
+	if len(keys) == 0 {
+		return keyError("set", key, ErrEmptyKey)
+	}
+
+	if val == nil {
+		return keyError("set", key, ErrNilValue)
+	}
+
+	b := tx.t.Bucket([]byte(tx.nameSpace))
+	if b == nil {
+		return keyError("set", key, ErrNotFound)
+	}
+
+	for i, k := range keys[:len(keys)-1] {
+		if b.Get([]byte(k)) != nil && tx.expired(keys[:i+1]) {
+			if err := tx.deleteLeaf(b, keys[:i+1]); err != nil {
+				return keyError("set", key, err)
+			}
+		}
+
+		b, err = b.CreateBucketIfNotExists([]byte(k))
+		if err != nil {
+			return keyError("set", key, boltError(err))
+		}
+	}
+
+	last := []byte(keys[len(keys)-1])
+	if b.Bucket(last) != nil {
+		return keyError("set", key, ErrIsBucket)
+	}
+
+	if err := b.Put(last, val); err != nil {
+		return keyError("set", key, boltError(err))
+	}
+
+	if err := tx.setRevision(keys); err != nil {
+		return keyError("set", key, err)
+	}
+
+	if tx.watch {
+		v := make([]byte, len(val))
+		copy(v, val)
+		tx.events = append(tx.events, Event{Kind: EventPut, Key: Key(keys).String(), Value: v})
+	}
+
+	return keyError("set", key, tx.setExpiry(keys, expires))
+}
+
+// createBucket creates a bucket at key along with buckets along the path,
+// leaving an existing bucket as it is. Expired leaves along the path are
+// replaced by buckets.
+func (tx *boltTx) createBucket(key string) error {
+	if err := tx.ctx.Err(); err != nil {
+		return keyError("create", key, err)
+	}
+
+	if !tx.t.Writable() {
+		return keyError("create", key, ErrReadOnly)
+	}
+
+	keys, err := splitKey(key)
+	if err != nil {
+		return keyError("create", key, err)
+	}
+
+	if len(keys) == 0 {
+		return keyError("create", key, ErrEmptyKey)
+	}
+
+	b := tx.t.Bucket([]byte(tx.nameSpace))
+	if b == nil {
+		return keyError("create", key, ErrNotFound)
+	}
+
+	for i, k := range keys {
+		if b.Get([]byte(k)) != nil && tx.expired(keys[:i+1]) {
+			if err := tx.deleteLeaf(b, keys[:i+1]); err != nil {
+				return keyError("create", key, err)
+			}
+		}
+
+		b, err = b.CreateBucketIfNotExists([]byte(k))
+		if err != nil {
+			return keyError("create", key, boltError(err))
+		}
+	}
+
+	return nil
+}
+
+// Get gets a value from a key.
+func (tx *boltTx) Get(key string) ([]byte, error) {
+	if err := tx.ctx.Err(); err != nil {
+		return nil, keyError("get", key, err)
+	}
+
+	keys, err := splitKey(key)
+	if err != nil {
+		return nil, keyError("get", key, err)
+	}
+
+	if len(keys) == 0 {
+		return nil, keyError("get", key, ErrEmptyKey)
+	}
+
+	b, err := tx.bucket(keys[:len(keys)-1])
+	if err != nil {
+		return nil, keyError("get", key, err)
+	}
+
+	last := []byte(keys[len(keys)-1])
+	v := b.Get(last)
+	if v == nil {
+		if b.Bucket(last) != nil {
+			return nil, keyError("get", key, ErrIsBucket)
+		}
+		return nil, keyError("get", key, ErrNotFound)
+	}
+
+	if tx.expired(keys) {
+		return nil, keyError("get", key, ErrNotFound)
+	}
+
+	// values returned by bolt are only valid during the transaction.
+	val := make([]byte, len(v))
+	copy(val, v)
+
+	return val, nil
+}
+
+// Delete deletes a key or a bucket along with everything in it.
+func (tx *boltTx) Delete(key string) error {
+	if err := tx.ctx.Err(); err != nil {
+		return keyError("delete", key, err)
+	}
+
+	if !tx.t.Writable() {
+		return keyError("delete", key, ErrReadOnly)
+	}
+
+	keys, err := splitKey(key)
+	if err != nil {
+		return keyError("delete", key, err)
+	}
+
+	if len(keys) == 0 {
+		return keyError("delete", key, ErrEmptyKey)
+	}
+
+	b, err := tx.bucket(keys[:len(keys)-1])
+	if err != nil {
+		return keyError("delete", key, err)
+	}
+
+	last := []byte(keys[len(keys)-1])
+	if b.Get(last) != nil {
+		if tx.expired(keys) {
+			return keyError("delete", key, ErrNotFound)
+		}
+		if tx.watch {
+			tx.events = append(tx.events, Event{Kind: EventDelete, Key: Key(keys).String()})
+		}
+		return keyError("delete", key, tx.deleteLeaf(b, keys))
+	}
+
+	if c := b.Bucket(last); c != nil {
+		if tx.watch {
+			if err := scanRange(tx.ctx, tx.cursor(c, keys), Key(keys).String(), nil, nil, false,
+				func(key string, _ []byte) error {
+					tx.events = append(tx.events, Event{Kind: EventDelete, Key: key})
+					return nil
+				}); err != nil {
+				return keyError("delete", key, err)
 			}
 		}
 
+		if err := b.DeleteBucket(last); err != nil {
+			return keyError("delete", key, boltError(err))
+		}
+		return keyError("delete", key, tx.clearLeaves(keys))
+	}
+
+	return keyError("delete", key, ErrNotFound)
+}
+
+// SetWithTTL sets a value that expires after ttl.
+func (tx *boltTx) SetWithTTL(key string, val []byte, ttl time.Duration) error {
+	if err := checkTTL(ttl); err != nil {
+		return keyError("set", key, err)
+	}
+
+	return tx.set(key, val, tx.now.Add(ttl))
+}
+
+// TTL returns the time left before a key expires.
+func (tx *boltTx) TTL(key string) (time.Duration, error) {
+	if _, err := tx.Get(key); err != nil {
+		return 0, keyError("ttl", key, err)
+	}
+
+	// key parses since Get succeeded.
+	keys, _ := splitKey(key)
+	return timeLeft(tx.expiresAt(keys), tx.now), nil
+}
+
+// expiries returns the bucket holding expiry times of keys, which is nil
+// if no key was ever set with a ttl.
+func (tx *boltTx) expiries() *bolt.Bucket {
+	return tx.t.Bucket(expiriesBucket(tx.nameSpace))
+}
+
+// expiresAt returns the time the leaf keys point to expires at, or zero if
+// it does not expire.
+func (tx *boltTx) expiresAt(keys []string) time.Time {
+	b := tx.expiries()
+	if b == nil {
+		return time.Time{}
+	}
+
+	return decodeExpiry(b.Get([]byte(Key(keys).path())))
+}
+
+// expiry returns the time the leaf at key expires at, or zero if it does not expire.
+func (tx *boltTx) expiry(key string) time.Time {
+	keys, err := splitKey(key)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return tx.expiresAt(keys)
+}
+
+// expired reports whether the leaf keys point to has expired.
+func (tx *boltTx) expired(keys []string) bool {
+	return expired(tx.expiresAt(keys), tx.now)
+}
+
+// setExpiry records when the leaf keys point to expires, removing any
+// expiry if expires is zero.
+func (tx *boltTx) setExpiry(keys []string, expires time.Time) error {
+	name := []byte(Key(keys).path())
+	if expires.IsZero() {
+		if b := tx.expiries(); b != nil && b.Get(name) != nil {
+			return boltError(b.Delete(name))
+		}
+		return nil
+	}
+
+	b, err := tx.t.CreateBucketIfNotExists(expiriesBucket(tx.nameSpace))
+	if err != nil {
+		return boltError(err)
+	}
+
+	return boltError(b.Put(name, encodeExpiry(expires)))
+}
+
+// clearLeaves removes expiry times and revisions of all leaves under the
+// bucket keys point to.
+func (tx *boltTx) clearLeaves(keys []string) error {
+	prefix := Key(keys).path() + "/"
+	for _, b := range []*bolt.Bucket{tx.expiries(), tx.revisions()} {
+		if b == nil {
+			continue
+		}
+
+		// bolt cursors do not support deleting while iterating.
+		var names [][]byte
 		c := b.Cursor()
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			if v != nil {
-				list = append(list, filepath.Join(key, string(k)))
-			} else {
-				if subList, err := kv.Enumerate(filepath.Join(key, string(k))); err != nil {
-					return err
-				} else {
-					list = append(list, subList...)
-				}
+		for k, _ := c.Seek([]byte(prefix)); k != nil && strings.HasPrefix(string(k), prefix); k, _ = c.Next() {
+			names = append(names, append([]byte(nil), k...))
+		}
+
+		for _, name := range names {
+			if err := b.Delete(name); err != nil {
+				return boltError(err)
 			}
 		}
+	}
+
+	return nil
+}
+
+// revisions returns the bucket holding revisions of leaves, which is nil
+// if no key was ever set since revisions were recorded.
+func (tx *boltTx) revisions() *bolt.Bucket {
+	return tx.t.Bucket(revisionsBucket(tx.nameSpace))
+}
+
+// setRevision records a new revision for the leaf keys point to.
+func (tx *boltTx) setRevision(keys []string) error {
+	seq, err := tx.t.CreateBucketIfNotExists(sequenceBucket)
+	if err != nil {
+		return boltError(err)
+	}
+
+	rev, err := seq.NextSequence()
+	if err != nil {
+		return boltError(err)
+	}
+
+	b, err := tx.t.CreateBucketIfNotExists(revisionsBucket(tx.nameSpace))
+	if err != nil {
+		return boltError(err)
+	}
+
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, rev)
+	return boltError(b.Put([]byte(Key(keys).path()), v))
+}
+
+// getRevision gets a value along with the version of its revision. Values
+// written before revisions were recorded are versioned by their content.
+func (tx *boltTx) getRevision(key string) ([]byte, Version, error) {
+	val, err := tx.Get(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if b := tx.revisions(); b != nil {
+		// key parses since Get succeeded.
+		keys, _ := splitKey(key)
+		if v := b.Get([]byte(Key(keys).path())); len(v) == 8 {
+			return val, revisionVersion(binary.BigEndian.Uint64(v)), nil
+		}
+	}
+
+	return val, versionOf(val), nil
+}
+
+// deleteLeaf deletes the leaf keys point to within its parent bucket b
+// along with its expiry time and revision.
+func (tx *boltTx) deleteLeaf(b *bolt.Bucket, keys []string) error {
+	if err := b.Delete([]byte(keys[len(keys)-1])); err != nil {
+		return boltError(err)
+	}
+
+	if r := tx.revisions(); r != nil {
+		if err := r.Delete([]byte(Key(keys).path())); err != nil {
+			return boltError(err)
+		}
+	}
+
+	return tx.setExpiry(keys, time.Time{})
+}
+
+// Enumerate lists all keys under a key.
+func (tx *boltTx) Enumerate(key string) ([]string, error) {
+	var list []string
+	if err := tx.walk(key, func(key string, _ []byte) error {
+		list = append(list, key)
 		return nil
-	})
+	}); err != nil {
+		return nil, keyError("enumerate", key, err)
+	}
+
+	return list, nil
+}
 
-	return list, err
+// walk calls f for every leaf under the bucket key points to in sorted order.
+func (tx *boltTx) walk(key string, f func(key string, val []byte) error) error {
+	// Get the bucket on which we would iterate for keys
+	b, keys, err := tx.bucketAt(key)
+	if err != nil {
+		return err
+	}
+
+	return scanRange(tx.ctx, tx.cursor(b, keys), key, nil, nil, false, f)
+}
+
+// boltCursor implements cursor over a bolt cursor, skipping leaves that
+// have expired at now.
+type boltCursor struct {
+	c *bolt.Cursor
+	k []byte
+	v []byte
+	// path is the path of the bucket within the namespace.
+	path string
+	// ttl holds expiry times of keys and is nil if there are none.
+	ttl *bolt.Bucket
+	now time.Time
+}
+
+// cursor returns a cursor over b, which keys point to.
+func (tx *boltTx) cursor(b *bolt.Bucket, keys []string) *boltCursor {
+	return &boltCursor{c: b.Cursor(), path: Key(keys).path(), ttl: tx.expiries(), now: tx.now}
+}
+
+// at records the position the underlying cursor moved to, moving further
+// along with move past expired leaves.
+func (c *boltCursor) at(k, v []byte, move func() ([]byte, []byte)) (string, bool) {
+	for k != nil && v != nil && c.expired(k) {
+		k, v = move()
+	}
+
+	c.k, c.v = k, v
+	if k == nil {
+		return "", false
+	}
+
+	return string(k), v != nil
+}
+
+// expired reports whether the leaf named k has expired.
+func (c *boltCursor) expired(k []byte) bool {
+	if c.ttl == nil {
+		return false
+	}
+
+	return expired(decodeExpiry(c.ttl.Get([]byte(joinKey(c.path, escapeSegment(string(k)))))), c.now)
+}
+
+func (c *boltCursor) First() (string, bool) {
+	k, v := c.c.First()
+	return c.at(k, v, c.c.Next)
+}
+
+func (c *boltCursor) Last() (string, bool) {
+	k, v := c.c.Last()
+	return c.at(k, v, c.c.Prev)
+}
+
+func (c *boltCursor) Next() (string, bool) {
+	k, v := c.c.Next()
+	return c.at(k, v, c.c.Next)
+}
+
+func (c *boltCursor) Prev() (string, bool) {
+	k, v := c.c.Prev()
+	return c.at(k, v, c.c.Prev)
+}
+
+func (c *boltCursor) Seek(name string) (string, bool) {
+	k, v := c.c.Seek([]byte(name))
+	return c.at(k, v, c.c.Next)
+}
+
+func (c *boltCursor) Value() []byte {
+	return c.v
+}
+
+func (c *boltCursor) Bucket() cursor {
+	return &boltCursor{
+		c:    c.c.Bucket().Bucket(c.k).Cursor(),
+		path: joinKey(c.path, escapeSegment(string(c.k))),
+		ttl:  c.ttl,
+		now:  c.now,
+	}
 }
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/sdeoras/kv"
+)
+
+// env is what commands run against.
+type env struct {
+	db     kv.KV
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// stater returns the store as a kv.Stater, failing with a usage error if it
+// cannot describe keys.
+func (e *env) stater() (kv.Stater, error) {
+	s, ok := e.db.(kv.Stater)
+	if !ok {
+		return nil, fmt.Errorf("%w: the store cannot describe keys", errUsage)
+	}
+
+	return s, nil
+}
+
+// lister returns the store as a kv.Lister, failing with a usage error if it
+// cannot list buckets.
+func (e *env) lister() (kv.Lister, error) {
+	l, ok := e.db.(kv.Lister)
+	if !ok {
+		return nil, fmt.Errorf("%w: the store cannot list buckets", errUsage)
+	}
+
+	return l, nil
+}
+
+// command is a subcommand of kvctl. flags defines its flags on a flag set
+// and returns the func running it. Standalone commands open stores of their
+// own and run without env.db.
+type command struct {
+	name             string
+	usage            string
+	minArgs, maxArgs int
+	flags            func(fs *flag.FlagSet) func(e *env, args []string) error
+	standalone       bool
+}
+
+var commands = []command{
+	{"get", "get [-o raw|hex|base64] <key>: print a value", 1, 1, getCmd, false},
+	{"set", "set [-f file] <key>: set a value read from stdin or a file", 1, 1, setCmd, false},
+	{"rm", "rm [-r] <key>: delete a key, or a bucket with -r", 1, 1, rmCmd, false},
+	{"ls", "ls [bucket]: list children of a bucket", 0, 1, lsCmd, false},
+	{"tree", "tree [bucket]: print all keys under a bucket as a tree", 0, 1, treeCmd, false},
+	{"stat", "stat <key>: describe a key", 1, 1, statCmd, false},
+	{"serve", "serve -resp <addr>: serve the store to Redis clients", 0, 0, serveCmd, false},
+	{"migrate", "migrate -from <url> -to <url> [-prefix bucket] [-checkpoint file] [-dry-run]: copy keys between stores", 0, 0, migrateCmd, true},
+}
+
+// arg returns the first of args, or the root if there is none.
+func arg(args []string) string {
+	if len(args) == 0 {
+		return "/"
+	}
+
+	return args[0]
+}
+
+func getCmd(fs *flag.FlagSet) func(e *env, args []string) error {
+	output := fs.String("o", "raw", "output encoding: raw, hex or base64")
+
+	return func(e *env, args []string) error {
+		var encode func([]byte) string
+		switch *output {
+		case "raw":
+			encode = func(b []byte) string { return string(b) }
+		case "hex":
+			encode = func(b []byte) string { return hex.EncodeToString(b) + "\n" }
+		case "base64":
+			encode = func(b []byte) string { return base64.StdEncoding.EncodeToString(b) + "\n" }
+		default:
+			return fmt.Errorf("%w: unknown output encoding %q", errUsage, *output)
+		}
+
+		val, err := e.db.Get(args[0])
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(e.stdout, encode(val))
+		return err
+	}
+}
+
+func setCmd(fs *flag.FlagSet) func(e *env, args []string) error {
+	file := fs.String("f", "", "file to read the value from instead of stdin")
+
+	return func(e *env, args []string) error {
+		var val []byte
+		var err error
+		if *file != "" {
+			val, err = ioutil.ReadFile(*file)
+		} else {
+			val, err = ioutil.ReadAll(e.stdin)
+		}
+		if err != nil {
+			return err
+		}
+
+		return e.db.Set(args[0], val)
+	}
+}
+
+func rmCmd(fs *flag.FlagSet) func(e *env, args []string) error {
+	recursive := fs.Bool("r", false, "delete buckets along with all keys in them")
+
+	return func(e *env, args []string) error {
+		st, err := e.stater()
+		if err != nil {
+			return err
+		}
+
+		info, err := st.Stat(args[0])
+		if err != nil {
+			return err
+		}
+
+		if !info.Exists {
+			return fmt.Errorf("%s: %w", args[0], kv.ErrNotFound)
+		}
+
+		if info.IsBucket && !*recursive {
+			return fmt.Errorf("%s: %w, use rm -r", args[0], kv.ErrIsBucket)
+		}
+
+		return e.db.Delete(args[0])
+	}
+}
+
+func lsCmd(*flag.FlagSet) func(e *env, args []string) error {
+	return func(e *env, args []string) error {
+		l, err := e.lister()
+		if err != nil {
+			return err
+		}
+
+		entries, err := l.List(arg(args))
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if _, err := fmt.Fprintln(e.stdout, entryName(entry)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func treeCmd(*flag.FlagSet) func(e *env, args []string) error {
+	return func(e *env, args []string) error {
+		root := arg(args)
+		if _, err := fmt.Fprintln(e.stdout, root); err != nil {
+			return err
+		}
+
+		return tree(e, root, "")
+	}
+}
+
+// tree prints children of bucket below each other, prefixing lines with indent.
+func tree(e *env, bucket, indent string) error {
+	l, err := e.lister()
+	if err != nil {
+		return err
+	}
+
+	entries, err := l.List(bucket)
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		branch, next := "├── ", "│   "
+		if i == len(entries)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		if _, err := fmt.Fprintln(e.stdout, indent+branch+entryName(entry)); err != nil {
+			return err
+		}
+
+		if entry.IsBucket {
+			if err := tree(e, entry.Key, indent+next); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// entryName returns the escaped name of an entry with a trailing slash for buckets.
+func entryName(entry kv.Entry) string {
+	name := entry.Name
+	if k, err := kv.NewKey(entry.Name); err == nil {
+		name = strings.TrimPrefix(k.String(), "/")
+	}
+	if entry.IsBucket {
+		name += "/"
+	}
+
+	return name
+}
+
+func statCmd(*flag.FlagSet) func(e *env, args []string) error {
+	return func(e *env, args []string) error {
+		st, err := e.stater()
+		if err != nil {
+			return err
+		}
+
+		info, err := st.Stat(args[0])
+		if err != nil {
+			return err
+		}
+
+		if !info.Exists {
+			return fmt.Errorf("%s: %w", args[0], kv.ErrNotFound)
+		}
+
+		if info.IsBucket {
+			_, err = fmt.Fprintf(e.stdout, "key:      %s\ntype:     bucket\nchildren: %d\n", info.Key, info.Children)
+		} else {
+			_, err = fmt.Fprintf(e.stdout, "key:      %s\ntype:     value\nsize:     %d\n", info.Key, info.Size)
+		}
+
+		return err
+	}
+}
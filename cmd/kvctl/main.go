@@ -0,0 +1,128 @@
+// Command kvctl inspects and edits key-value stores backed by bolt, memory,
+// a directory or Google cloud data-store.
+//
+// Usage:
+//
+//	kvctl [flags] <command> [command flags] [args]
+//
+// Commands are get, set, rm, ls, tree, stat, serve and migrate. Run kvctl -h for flags.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sdeoras/kv"
+)
+
+// errUsage is returned when kvctl is invoked incorrectly.
+var errUsage = errors.New("invalid usage")
+
+// store holds flags selecting the store to open.
+type store struct {
+	backend   string
+	dbFile    string
+	nameSpace string
+	projectID string
+}
+
+// open opens the store described by s.
+func (s *store) open() (kv.KV, kv.CloseFunc, error) {
+	switch s.backend {
+	case "bolt":
+		if s.dbFile == "" {
+			return nil, nil, fmt.Errorf("%w: -db is required for bolt", errUsage)
+		}
+		return kv.NewBoltKv(s.dbFile, s.nameSpace)
+	case "mem":
+		return kv.NewMemKv(), func() error { return nil }, nil
+	case "dir":
+		if s.dbFile == "" {
+			return nil, nil, fmt.Errorf("%w: -db is required for dir", errUsage)
+		}
+		return kv.NewDirKv(s.dbFile, s.nameSpace)
+	case "datastore":
+		if s.projectID == "" {
+			return nil, nil, fmt.Errorf("%w: -project or GOOGLE_PROJECT is required for datastore", errUsage)
+		}
+		return kv.NewDataStoreKv(context.Background(), s.projectID, s.nameSpace)
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown backend %q", errUsage, s.backend)
+	}
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "kvctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run parses args and runs a command reading from stdin and writing to stdout.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var s store
+	fs := flag.NewFlagSet("kvctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&s.backend, "backend", "bolt", "backend to open: bolt, mem, dir or datastore")
+	fs.StringVar(&s.dbFile, "db", "", "bolt database file or root directory of dir")
+	fs.StringVar(&s.nameSpace, "ns", "default", "namespace")
+	fs.StringVar(&s.projectID, "project", os.Getenv("GOOGLE_PROJECT"), "Google cloud project of datastore")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: kvctl [flags] <command> [command flags] [args]")
+		fmt.Fprintln(stderr, "\ncommands:")
+		for _, c := range commands {
+			fmt.Fprintf(stderr, "  %-5s %s\n", c.name, c.usage)
+		}
+		fmt.Fprintln(stderr, "\nflags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: missing command", errUsage)
+	}
+
+	name := fs.Arg(0)
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+
+		cfs := flag.NewFlagSet("kvctl "+name, flag.ContinueOnError)
+		cfs.SetOutput(stderr)
+		f := c.flags(cfs)
+		if err := cfs.Parse(fs.Args()[1:]); err != nil {
+			return err
+		}
+
+		if n := cfs.NArg(); n < c.minArgs || n > c.maxArgs {
+			return fmt.Errorf("%w: %s", errUsage, c.usage)
+		}
+
+		e := &env{stdin: stdin, stdout: stdout}
+		if c.standalone {
+			return f(e, cfs.Args())
+		}
+
+		db, closeKv, err := s.open()
+		if err != nil {
+			return err
+		}
+		defer closeKv()
+
+		e.db = db
+		return f(e, cfs.Args())
+	}
+
+	return fmt.Errorf("%w: unknown command %q", errUsage, name)
+}
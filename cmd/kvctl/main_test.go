@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sdeoras/kv"
+)
+
+// newDB returns a bolt file in a fresh temp dir along with a func removing it.
+func newDB(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "kvctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filepath.Join(dir, "bolt.db"), func() { _ = os.RemoveAll(dir) }
+}
+
+// kvctl runs kvctl against dbFile with stdin, returning what it printed.
+func kvctl(t *testing.T, dbFile, stdin string, args ...string) (string, error) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	err := run(append([]string{"-db", dbFile}, args...), strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+// expectOutput fails the test unless kvctl succeeds printing expected.
+func expectOutput(t *testing.T, dbFile, expected string, args ...string) {
+	t.Helper()
+
+	out, err := kvctl(t, dbFile, "", args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out != expected {
+		t.Fatalf("kvctl %v: expected %q, got: %q", args, expected, out)
+	}
+}
+
+func TestKvctl(t *testing.T) {
+	dbFile, done := newDB(t)
+	defer done()
+
+	if _, err := kvctl(t, dbFile, "val", "set", "/a/b/c/myKey"); err != nil {
+		t.Fatal(err)
+	}
+
+	valFile := filepath.Join(filepath.Dir(dbFile), "val")
+	if err := ioutil.WriteFile(valFile, []byte("otherVal"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := kvctl(t, dbFile, "", "set", "-f", valFile, "/a/otherKey"); err != nil {
+		t.Fatal(err)
+	}
+
+	expectOutput(t, dbFile, "val", "get", "/a/b/c/myKey")
+	expectOutput(t, dbFile, "76616c\n", "get", "-o", "hex", "/a/b/c/myKey")
+	expectOutput(t, dbFile, "dmFs\n", "get", "-o", "base64", "/a/b/c/myKey")
+	expectOutput(t, dbFile, "b/\notherKey\n", "ls", "/a")
+	expectOutput(t, dbFile, "/\n└── a/\n    ├── b/\n    │   └── c/\n    │       └── myKey\n    └── otherKey\n", "tree")
+	expectOutput(t, dbFile, "key:      /a/b/c/myKey\ntype:     value\nsize:     3\n", "stat", "/a/b/c/myKey")
+	expectOutput(t, dbFile, "key:      /a\ntype:     bucket\nchildren: 2\n", "stat", "/a")
+
+	if _, err := kvctl(t, dbFile, "", "rm", "/a/b"); !errors.Is(err, kv.ErrIsBucket) {
+		t.Fatal("expected ErrIsBucket, got:", err)
+	}
+
+	if _, err := kvctl(t, dbFile, "", "rm", "-r", "/a/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := kvctl(t, dbFile, "", "rm", "/a/otherKey"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := kvctl(t, dbFile, "", "stat", "/a/otherKey"); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatal("expected ErrNotFound, got:", err)
+	}
+}
+
+func TestKvctl_Usage(t *testing.T) {
+	dbFile, done := newDB(t)
+	defer done()
+
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"get"},
+		{"get", "-o", "octal", "/a"},
+		{"-backend", "unknown", "ls"},
+		{"serve"},
+	} {
+		if _, err := kvctl(t, dbFile, "", args...); !errors.Is(err, errUsage) {
+			t.Fatalf("kvctl %v: expected usage error, got: %v", args, err)
+		}
+	}
+}
+
+func TestKvctl_Unsupported(t *testing.T) {
+	// embedding only kv.KV hides kv.Stater and kv.Lister.
+	e := &env{db: struct{ kv.KV }{kv.NewMemKv()}, stdout: ioutil.Discard}
+	for _, c := range commands {
+		switch c.name {
+		case "rm", "ls", "tree", "stat":
+		default:
+			continue
+		}
+
+		f := c.flags(flag.NewFlagSet(c.name, flag.ContinueOnError))
+		if err := f(e, []string{"/a"}); !errors.Is(err, errUsage) {
+			t.Fatalf("kvctl %s: expected usage error, got: %v", c.name, err)
+		}
+	}
+}
+
+// newBolt opens a bolt file as a kv.KV for seeding and checking migrations.
+func newBolt(t *testing.T, dbFile, ns string) (kv.KV, kv.CloseFunc) {
+	t.Helper()
+
+	db, closeKv, err := kv.NewBoltKv(dbFile, ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db, closeKv
+}
+
+func TestKvctl_Migrate(t *testing.T) {
+	srcFile, done := newDB(t)
+	defer done()
+	dir := filepath.Dir(srcFile)
+	dstFile := filepath.Join(dir, "dst.db")
+	cpFile := filepath.Join(dir, "checkpoint.json")
+
+	src, closeSrc := newBolt(t, srcFile, "a")
+	keys := []string{"/k/0", "/k/1", "/k/2", "/k/3", "/k/4", "/other/key"}
+	for _, key := range keys {
+		if err := src.Set(key, []byte("val"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = closeSrc()
+
+	// an interrupted run copied the first three keys.
+	dst, closeDst := newBolt(t, dstFile, "b")
+	for _, key := range keys[:3] {
+		if err := dst.Set(key, []byte("val"+key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = closeDst()
+
+	from := "bolt://" + srcFile + "?ns=a"
+	to := "bolt://" + dstFile + "?ns=b"
+	cp := &checkpoint{From: from, To: to, Prefix: "/k", Last: "/k/2", Copied: 3}
+	if err := cp.save(cpFile); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"migrate", "-from", from, "-to", to, "-prefix", "/k", "-checkpoint", cpFile}
+	out, err := kvctl(t, "", "", append(args, "-dry-run")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out, "resuming after /k/2") || !strings.Contains(out, "would copy 2 keys") {
+		t.Fatal("unexpected dry run output:", out)
+	}
+
+	out, err = kvctl(t, "", "", args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out, "copied 5 keys") || !strings.Contains(out, "verified") {
+		t.Fatal("unexpected output:", out)
+	}
+
+	if _, err := os.Stat(cpFile); !os.IsNotExist(err) {
+		t.Fatal("expected checkpoint to be removed, got:", err)
+	}
+
+	dst, closeDst = newBolt(t, dstFile, "b")
+	defer closeDst()
+
+	copied, err := dst.Enumerate("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(copied, " ") != strings.Join(keys[:5], " ") {
+		t.Fatal("unexpected keys:", copied)
+	}
+}
+
+func TestKvctl_MigrateVerify(t *testing.T) {
+	srcFile, done := newDB(t)
+	defer done()
+	dstFile := filepath.Join(filepath.Dir(srcFile), "dst.db")
+
+	src, closeSrc := newBolt(t, srcFile, "default")
+	if err := src.Set("/k/0", []byte("val")); err != nil {
+		t.Fatal(err)
+	}
+	_ = closeSrc()
+
+	dst, closeDst := newBolt(t, dstFile, "default")
+	if err := dst.Set("/k/extra", []byte("val")); err != nil {
+		t.Fatal(err)
+	}
+	_ = closeDst()
+
+	// keys the destination held before are left alone.
+	out, err := kvctl(t, "", "", "migrate", "-from", "bolt://"+srcFile, "-to", "bolt://"+dstFile,
+		"-checkpoint", filepath.Join(filepath.Dir(srcFile), "checkpoint.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out, "copied 1 keys") || !strings.Contains(out, "verified") {
+		t.Fatal("unexpected output:", out)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	src, dst := kv.NewMemKv(), kv.NewMemKv()
+	for _, db := range []kv.KV{src, dst} {
+		if err := db.Set("/k/0", []byte("val")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := dst.Set("/k/extra", []byte("val")); err != nil {
+		t.Fatal(err)
+	}
+
+	// keys deleted from the source since they were enumerated are skipped.
+	if err := verify(src, dst, []string{"/k/0", "/k/deleted"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dst.Set("/k/0", []byte("other")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verify(src, dst, []string{"/k/0"}); err == nil || !strings.Contains(err.Error(), "checksums differ") {
+		t.Fatal("expected checksums to differ, got:", err)
+	}
+
+	if err := dst.Delete("/k/0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verify(src, dst, []string{"/k/0"}); err == nil || !strings.Contains(err.Error(), "1 keys in source, 0 in destination") {
+		t.Fatal("expected key counts to differ, got:", err)
+	}
+}
+
+func TestKvctl_MigrateInvalidCheckpoint(t *testing.T) {
+	srcFile, done := newDB(t)
+	defer done()
+	dir := filepath.Dir(srcFile)
+	cpFile := filepath.Join(dir, "checkpoint.json")
+
+	src, closeSrc := newBolt(t, srcFile, "default")
+	if err := src.Set("/k/0", []byte("val")); err != nil {
+		t.Fatal(err)
+	}
+	_ = closeSrc()
+
+	from := "bolt://" + srcFile
+	to := "bolt://" + filepath.Join(dir, "dst.db")
+	cp := &checkpoint{From: from, To: to, Prefix: "/", Last: "/k/../0", Copied: 1}
+	if err := cp.save(cpFile); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := kvctl(t, "", "", "migrate", "-from", from, "-to", to, "-checkpoint", cpFile)
+	if err == nil || !strings.Contains(err.Error(), kv.ErrInvalidKey.Error()) {
+		t.Fatal("expected invalid checkpoint, got:", err)
+	}
+}
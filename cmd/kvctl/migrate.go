@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/url"
+	"os"
+
+	"github.com/sdeoras/kv"
+)
+
+// openURL opens a store described by a URL such as bolt:///data.db?ns=a,
+// dir:///data?ns=a, datastore://project?ns=a or mem://. The namespace defaults to default.
+func openURL(raw string) (kv.KV, kv.CloseFunc, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	ns := u.Query().Get("ns")
+	if ns == "" {
+		ns = "default"
+	}
+
+	switch u.Scheme {
+	case "bolt":
+		dbFile := u.Host + u.Path
+		if dbFile == "" {
+			return nil, nil, fmt.Errorf("%w: missing bolt database file in %s", errUsage, raw)
+		}
+		return kv.NewBoltKv(dbFile, ns)
+	case "dir":
+		root := u.Host + u.Path
+		if root == "" {
+			return nil, nil, fmt.Errorf("%w: missing root directory in %s", errUsage, raw)
+		}
+		return kv.NewDirKv(root, ns)
+	case "datastore":
+		if u.Host == "" {
+			return nil, nil, fmt.Errorf("%w: missing project in %s", errUsage, raw)
+		}
+		return kv.NewDataStoreKv(context.Background(), u.Host, ns)
+	case "mem":
+		return kv.NewMemKv(), func() error { return nil }, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown store %s", errUsage, raw)
+	}
+}
+
+// checkpoint records progress of a migration. Keys are copied in sorted order,
+// so the last key copied tells where to resume.
+type checkpoint struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Prefix string `json:"prefix"`
+	Last   string `json:"last"`
+	Copied int    `json:"copied"`
+}
+
+// load reads a checkpoint from file, leaving c as is if file does not exist.
+func (c *checkpoint) load(file string) error {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var saved checkpoint
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return fmt.Errorf("checkpoint %s: %v", file, err)
+	}
+
+	if saved.Last != "" {
+		if _, err := kv.ParseKey(saved.Last); err != nil {
+			return fmt.Errorf("checkpoint %s: %v", file, err)
+		}
+	}
+
+	if saved.From != c.From || saved.To != c.To || saved.Prefix != c.Prefix {
+		return fmt.Errorf("checkpoint %s belongs to a migration from %s to %s under %s",
+			file, saved.From, saved.To, saved.Prefix)
+	}
+
+	*c = saved
+	return nil
+}
+
+// save writes c to file, replacing it atomically so that an interrupted
+// write leaves the previous checkpoint in place.
+func (c *checkpoint) save(file string) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
+
+func migrateCmd(fs *flag.FlagSet) func(e *env, args []string) error {
+	from := fs.String("from", "", "store to copy from, e.g. bolt:///data.db?ns=a")
+	to := fs.String("to", "", "store to copy to, e.g. datastore://project?ns=a")
+	prefix := fs.String("prefix", "/", "bucket to copy")
+	file := fs.String("checkpoint", "kvctl-migrate.json", "file recording progress to resume from")
+	every := fs.Int("checkpoint-every", 100, "number of keys copied between checkpoints")
+	dryRun := fs.Bool("dry-run", false, "list what would be copied without writing")
+
+	return func(e *env, args []string) error {
+		if *from == "" || *to == "" {
+			return fmt.Errorf("%w: -from and -to are required", errUsage)
+		}
+
+		if *every < 1 {
+			return fmt.Errorf("%w: -checkpoint-every must be positive", errUsage)
+		}
+
+		src, closeSrc, err := openURL(*from)
+		if err != nil {
+			return err
+		}
+		defer closeSrc()
+
+		keys, err := src.Enumerate(*prefix)
+		if err != nil {
+			return err
+		}
+
+		cp := &checkpoint{From: *from, To: *to, Prefix: *prefix}
+		if err := cp.load(*file); err != nil {
+			return err
+		}
+
+		// skip keys copied by an earlier run.
+		todo := keys
+		if cp.Last != "" {
+			if todo, err = keysAfter(keys, cp.Last); err != nil {
+				return err
+			}
+			fmt.Fprintf(e.stdout, "resuming after %s with %d keys copied\n", cp.Last, cp.Copied)
+		}
+
+		if *dryRun {
+			n, size := 0, 0
+			for _, key := range todo {
+				val, err := src.Get(key)
+				if errors.Is(err, kv.ErrNotFound) {
+					// deleted or expired since it was enumerated.
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				n++
+				size += len(val)
+				fmt.Fprintf(e.stdout, "%s (%d bytes)\n", key, len(val))
+			}
+
+			fmt.Fprintf(e.stdout, "would copy %d keys (%d bytes) from %s to %s\n", n, size, *from, *to)
+			return nil
+		}
+
+		dst, closeDst, err := openURL(*to)
+		if err != nil {
+			return err
+		}
+		defer closeDst()
+
+		for i, key := range todo {
+			val, err := src.Get(key)
+			if errors.Is(err, kv.ErrNotFound) {
+				// deleted or expired since it was enumerated.
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := dst.Set(key, val); err != nil {
+				return err
+			}
+
+			cp.Last = key
+			cp.Copied++
+			if (i+1)%*every == 0 {
+				if err := cp.save(*file); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := cp.save(*file); err != nil {
+			return err
+		}
+		fmt.Fprintf(e.stdout, "copied %d keys from %s to %s\n", cp.Copied, *from, *to)
+
+		if err := verify(src, dst, keys); err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, "verified key counts and checksums")
+
+		return os.Remove(*file)
+	}
+}
+
+// verify compares the number of keys copied to dst along with a checksum of
+// keys and values, checking only those of keys still in src. Other keys dst
+// may hold are ignored.
+func verify(src, dst kv.KV, keys []string) error {
+	srcCount, srcSum, keys, err := checksum(src, keys)
+	if err != nil {
+		return err
+	}
+
+	dstCount, dstSum, _, err := checksum(dst, keys)
+	if err != nil {
+		return err
+	}
+
+	if srcCount != dstCount {
+		return fmt.Errorf("verification failed: %d keys in source, %d in destination", srcCount, dstCount)
+	}
+
+	if srcSum != dstSum {
+		return fmt.Errorf("verification failed: checksums differ, %s in source, %s in destination", srcSum, dstSum)
+	}
+
+	return nil
+}
+
+// checksum counts those of keys db holds and hashes them along with their
+// values in the order given, returning the keys it found.
+func checksum(db kv.KV, keys []string) (int, string, []string, error) {
+	var found []string
+	h := sha256.New()
+	for _, key := range keys {
+		val, err := db.Get(key)
+		if errors.Is(err, kv.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, "", nil, err
+		}
+
+		writeField(h, []byte(key))
+		writeField(h, val)
+		found = append(found, key)
+	}
+
+	return len(found), fmt.Sprintf("%x", h.Sum(nil)), found, nil
+}
+
+// writeField writes b prefixed with its length so that fields cannot run
+// into each other.
+func writeField(h hash.Hash, b []byte) {
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+	h.Write(n[:])
+	h.Write(b)
+}
+
+// keysAfter returns the keys following last, given keys in the order
+// Enumerate lists them in.
+func keysAfter(keys []string, last string) ([]string, error) {
+	lastKey, err := kv.ParseKey(last)
+	if err != nil {
+		return nil, err
+	}
+
+	for len(keys) > 0 {
+		k, err := kv.ParseKey(keys[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if lastKey.Less(k) {
+			break
+		}
+		keys = keys[1:]
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+
+	"github.com/sdeoras/kv/resp"
+)
+
+func serveCmd(fs *flag.FlagSet) func(e *env, args []string) error {
+	addr := fs.String("resp", "", "address to serve RESP on, e.g. :6379")
+
+	return func(e *env, args []string) error {
+		if *addr == "" {
+			return fmt.Errorf("%w: -resp is required", errUsage)
+		}
+
+		l, err := net.Listen("tcp", *addr)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(e.stdout, "serving RESP on %s\n", l.Addr())
+		return resp.Serve(l, e.db)
+	}
+}
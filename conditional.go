@@ -0,0 +1,25 @@
+package kv
+
+import "errors"
+
+// setIfAbsent sets a value within tx only if key does not exist.
+func setIfAbsent(tx Tx, key string, val []byte) error {
+	_, err := tx.Get(key)
+	switch {
+	case err == nil:
+		return keyError("set", key, ErrExists)
+	case !errors.Is(err, ErrNotFound):
+		return err
+	}
+
+	return tx.Set(key, val)
+}
+
+// replace sets a value within tx only if key already exists.
+func replace(tx Tx, key string, val []byte) error {
+	if _, err := tx.Get(key); err != nil {
+		return keyError("set", key, err)
+	}
+
+	return tx.Set(key, val)
+}
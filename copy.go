@@ -0,0 +1,82 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// copyTx is a transaction that can copy keys along with their expiry times.
+// Transactions of every backend in this package implement it.
+type copyTx interface {
+	Tx
+	// set sets a value that expires at expires, or never if expires is zero.
+	set(key string, val []byte, expires time.Time) error
+	// expiry returns the time an existing leaf expires at, or zero if it
+	// does not expire.
+	expiry(key string) time.Time
+	// createBucket creates a bucket at key along with buckets along the
+	// path, leaving an existing bucket as it is.
+	createBucket(key string) error
+}
+
+// copyKeys copies the leaf or bucket at src to dst within tx, deleting src
+// afterwards if move is set. dst must not exist and must not lie within src.
+func copyKeys(tx copyTx, src, dst string, move bool) error {
+	s, err := splitKey(src)
+	if err != nil {
+		return err
+	}
+
+	d, err := splitKey(dst)
+	if err != nil {
+		return err
+	}
+
+	if len(s) == 0 || len(d) == 0 {
+		return ErrEmptyKey
+	}
+
+	if hasPrefix(d, s) {
+		return fmt.Errorf("%w: %s lies within %s", ErrInvalidKey, d, s)
+	}
+
+	if _, err := tx.Get(dst); err == nil || errors.Is(err, ErrIsBucket) {
+		return ErrExists
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	keys := []string{s.String()}
+	if _, err := tx.Get(src); errors.Is(err, ErrIsBucket) {
+		if keys, err = tx.Enumerate(src); err != nil {
+			return err
+		}
+
+		// create dst first so that copying an empty bucket does not lose it.
+		if err := tx.createBucket(d.String()); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		val, err := tx.Get(key)
+		if err != nil {
+			return err
+		}
+
+		rel := splitPath(key)[len(s):]
+		target := append(append(Key(nil), d...), rel...)
+		if err := tx.set(target.String(), val, tx.expiry(key)); err != nil {
+			return err
+		}
+	}
+
+	if move {
+		return tx.Delete(src)
+	}
+
+	return nil
+}
@@ -0,0 +1,782 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	_ KVContext       = (*dirKv)(nil)
+	_ Versioned       = (*dirKv)(nil)
+	_ Conditional     = (*dirKv)(nil)
+	_ Iterator        = (*dirKv)(nil)
+	_ Pager           = (*dirKv)(nil)
+	_ Scanner         = (*dirKv)(nil)
+	_ ValueEnumerator = (*dirKv)(nil)
+	_ Lister          = (*dirKv)(nil)
+	_ Stater          = (*dirKv)(nil)
+)
+
+const (
+	// tmpPrefix names files being written before they are renamed into place.
+	tmpPrefix = "%tmp-"
+	// delPrefix names directories being removed after they were renamed out of
+	// the way. Neither prefix decodes to a segment, so that such files and
+	// directories are never listed as keys.
+	delPrefix = "%del-"
+)
+
+// dirLocks maps the absolute directory of a namespace to the lock shared by
+// every dirKv of this process open on it.
+var dirLocks sync.Map
+
+// dirKv implements KV interface using a directory tree as backend, mapping
+// buckets onto directories and leaves onto files holding their values. Its
+// lock is shared within a process but not across processes, so that only a
+// single process may open a root at a time.
+type dirKv struct {
+	// mu is held for writing by update operations and for reading by read
+	// operations, so that reads never observe a tree changed half way.
+	mu *sync.RWMutex
+	// dir is the directory of the namespace.
+	dir    string
+	closed bool
+}
+
+// dirTx implements Tx over the directory tree of a dirKv. Writes are applied
+// as they are made and are not rolled back, but since a dirTx is only used
+// while holding the lock of its namespace, a check followed by a write is
+// atomic with respect to other operations of this process.
+type dirTx struct {
+	ctx context.Context
+	dir string
+}
+
+// newDirKv provides a new instance of KV with a directory tree under root
+// as backend. Each namespace is a directory within root.
+func newDirKv(root, nameSpace string) (*dirKv, func() error, error) {
+	if err := checkNamespace(nameSpace); err != nil {
+		return nil, nil, namespaceError("open", nameSpace, err)
+	}
+
+	if nameSpace == "." || nameSpace == ".." {
+		return nil, nil, namespaceError("open", nameSpace, fmt.Errorf("%w: reserved name", ErrInvalidNamespace))
+	}
+
+	dir := filepath.Join(root, encodeName(nameSpace))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, namespaceError("open", nameSpace, err)
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, namespaceError("open", nameSpace, err)
+	}
+
+	mu, _ := dirLocks.LoadOrStore(abs, new(sync.RWMutex))
+	kv := &dirKv{mu: mu.(*sync.RWMutex), dir: dir}
+	return kv, kv.close, nil
+}
+
+// close marks kv closed. Nothing is held open between operations.
+func (kv *dirKv) close() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	kv.closed = true
+	return nil
+}
+
+// view runs f holding the lock for reading.
+func (kv *dirKv) view(ctx context.Context, f func(tx *dirTx) error) error {
+	kv.mu.RLock()
+	defer kv.mu.RUnlock()
+
+	if kv.closed {
+		return ErrClosed
+	}
+
+	return f(&dirTx{ctx: ctx, dir: kv.dir})
+}
+
+// update runs f holding the lock for writing.
+func (kv *dirKv) update(ctx context.Context, f func(tx *dirTx) error) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	if kv.closed {
+		return ErrClosed
+	}
+
+	return f(&dirTx{ctx: ctx, dir: kv.dir})
+}
+
+// Set sets a value at a key.
+func (kv *dirKv) Set(key string, val []byte) error {
+	return kv.SetContext(context.Background(), key, val)
+}
+
+// SetContext sets a value at a key honoring ctx.
+func (kv *dirKv) SetContext(ctx context.Context, key string, val []byte) error {
+	return keyError("set", key, kv.update(ctx, func(tx *dirTx) error {
+		return tx.Set(key, val)
+	}))
+}
+
+// Get gets a value from a key.
+func (kv *dirKv) Get(key string) ([]byte, error) {
+	return kv.GetContext(context.Background(), key)
+}
+
+// GetContext gets a value from a key honoring ctx.
+func (kv *dirKv) GetContext(ctx context.Context, key string) ([]byte, error) {
+	var val []byte
+	err := kv.view(ctx, func(tx *dirTx) error {
+		var err error
+		val, err = tx.Get(key)
+		return err
+	})
+
+	return val, keyError("get", key, err)
+}
+
+// Delete delets a key.
+func (kv *dirKv) Delete(key string) error {
+	return kv.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext deletes a key honoring ctx.
+func (kv *dirKv) DeleteContext(ctx context.Context, key string) error {
+	return keyError("delete", key, kv.update(ctx, func(tx *dirTx) error {
+		return tx.Delete(key)
+	}))
+}
+
+// Enumerate lists all keys under a key.
+func (kv *dirKv) Enumerate(key string) ([]string, error) {
+	return kv.EnumerateContext(context.Background(), key)
+}
+
+// EnumerateContext lists all keys under a key honoring ctx by walking the
+// directory tree.
+func (kv *dirKv) EnumerateContext(ctx context.Context, key string) ([]string, error) {
+	var list []string
+	err := kv.view(ctx, func(tx *dirTx) error {
+		var err error
+		list, err = tx.Enumerate(key)
+		return err
+	})
+
+	return list, keyError("enumerate", key, err)
+}
+
+// EnumerateValues lists all keys under a key along with their values
+// in a single walk.
+func (kv *dirKv) EnumerateValues(key string) ([]Pair, error) {
+	s := &scanCollector{values: true}
+	if err := kv.view(context.Background(), func(tx *dirTx) error {
+		return tx.walk(key, s.add)
+	}); err != nil {
+		return nil, keyError("enumerate", key, err)
+	}
+
+	return s.pairs, nil
+}
+
+// List lists the immediate children of a bucket.
+func (kv *dirKv) List(prefix string) ([]Entry, error) {
+	var entries []Entry
+	if err := kv.view(context.Background(), func(tx *dirTx) error {
+		dir, _, err := tx.bucketAt(prefix)
+		if err != nil {
+			return err
+		}
+
+		entries, err = listCursor(tx.ctx, newDirCursor(dir), prefix)
+		return err
+	}); err != nil {
+		return nil, keyError("list", prefix, err)
+	}
+
+	return entries, nil
+}
+
+// Stat describes a key.
+func (kv *dirKv) Stat(key string) (Info, error) {
+	var info Info
+	err := kv.view(context.Background(), func(tx *dirTx) error {
+		keys, err := splitKey(key)
+		if err != nil {
+			return err
+		}
+
+		if len(keys) == 0 {
+			return ErrEmptyKey
+		}
+
+		dir, err := tx.bucket(keys[:len(keys)-1])
+		if err != nil {
+			return err
+		}
+
+		p := filepath.Join(dir, encodeName(keys[len(keys)-1]))
+		fi, err := os.Stat(p)
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if !fi.IsDir() {
+			info.Size = int(fi.Size())
+			return nil
+		}
+
+		info.IsBucket = true
+		info.Children = countChildren(newDirCursor(p))
+		return nil
+	})
+
+	return statResult(key, info, err)
+}
+
+// Exists reports whether a key exists.
+func (kv *dirKv) Exists(key string) bool {
+	info, err := kv.Stat(key)
+	return err == nil && info.Exists
+}
+
+// GetWithVersion gets a value along with its version. Versions are derived
+// from the content of values, since files have nowhere to keep a revision.
+func (kv *dirKv) GetWithVersion(key string) ([]byte, Version, error) {
+	var val []byte
+	var version Version
+	err := kv.view(context.Background(), func(tx *dirTx) error {
+		var err error
+		val, version, err = getWithVersion(tx, key)
+		return err
+	})
+
+	return val, version, keyError("get", key, err)
+}
+
+// SetIfVersion sets a value if key is still at version.
+func (kv *dirKv) SetIfVersion(key string, val []byte, version Version) error {
+	return keyError("set", key, kv.update(context.Background(), func(tx *dirTx) error {
+		return setIfVersion(tx, key, val, version)
+	}))
+}
+
+// DeleteIfVersion deletes a key if it is still at version.
+func (kv *dirKv) DeleteIfVersion(key string, version Version) error {
+	return keyError("delete", key, kv.update(context.Background(), func(tx *dirTx) error {
+		return deleteIfVersion(tx, key, version)
+	}))
+}
+
+// SetIfAbsent sets a value if key does not exist.
+func (kv *dirKv) SetIfAbsent(key string, val []byte) error {
+	return keyError("set", key, kv.update(context.Background(), func(tx *dirTx) error {
+		return setIfAbsent(tx, key, val)
+	}))
+}
+
+// Replace sets a value if key exists.
+func (kv *dirKv) Replace(key string, val []byte) error {
+	return keyError("set", key, kv.update(context.Background(), func(tx *dirTx) error {
+		return replace(tx, key, val)
+	}))
+}
+
+// Iterate calls f for every key under prefix along with its value,
+// reading each file as it is visited.
+func (kv *dirKv) Iterate(ctx context.Context, prefix string, f func(key string, val []byte) error) error {
+	return keyError("iterate", prefix, kv.view(ctx, func(tx *dirTx) error {
+		return tx.walk(prefix, f)
+	}))
+}
+
+// IterateKeys calls f for every key under prefix.
+func (kv *dirKv) IterateKeys(ctx context.Context, prefix string, f func(key string) error) error {
+	return kv.Iterate(ctx, prefix, func(key string, _ []byte) error { return f(key) })
+}
+
+// EnumeratePage lists a page of keys under prefix in the order defined by
+// Key.Less, seeking within sorted directory listings to the position token points to.
+func (kv *dirKv) EnumeratePage(prefix string, pageSize int, token string) ([]string, string, error) {
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	after, err := parsePageToken(token)
+	if err != nil {
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	p := &pageCollector{prefix: prefix, after: after, pageSize: pageSize}
+	if err := kv.view(context.Background(), func(tx *dirTx) error {
+		dir, _, err := tx.bucketAt(prefix)
+		if err != nil {
+			return err
+		}
+
+		return scanRange(tx.ctx, newDirCursor(dir), "", splitPath(after), nil, false, p.add)
+	}); err != nil && err != errStop {
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	keys, next := p.page()
+	return keys, next, nil
+}
+
+// Scan lists pairs under prefix within a range.
+func (kv *dirKv) Scan(prefix, start, end string, reverse bool, limit int) ([]Pair, error) {
+	s := &scanCollector{prefix: prefix, limit: limit, values: true}
+	if err := kv.scan(prefix, start, end, reverse, s); err != nil {
+		return nil, err
+	}
+
+	return s.pairs, nil
+}
+
+// ScanKeys lists keys under prefix within a range.
+func (kv *dirKv) ScanKeys(prefix, start, end string, reverse bool, limit int) ([]string, error) {
+	s := &scanCollector{prefix: prefix, limit: limit}
+	if err := kv.scan(prefix, start, end, reverse, s); err != nil {
+		return nil, err
+	}
+
+	return s.keys(), nil
+}
+
+func (kv *dirKv) scan(prefix, start, end string, reverse bool, s *scanCollector) error {
+	startKeys, endKeys, err := scanBounds(start, end)
+	if err != nil {
+		return keyError("scan", prefix, err)
+	}
+
+	if err := kv.view(context.Background(), func(tx *dirTx) error {
+		dir, _, err := tx.bucketAt(prefix)
+		if err != nil {
+			return err
+		}
+
+		return scanRange(tx.ctx, newDirCursor(dir), "", startKeys, endKeys, reverse, s.add)
+	}); err != nil && err != errStop {
+		return keyError("scan", prefix, err)
+	}
+
+	return nil
+}
+
+// bucketAt parses key and returns the directory it points to along with the
+// parsed key.
+func (tx *dirTx) bucketAt(key string) (string, Key, error) {
+	keys, err := splitKey(key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := tx.bucket(keys)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return dir, keys, nil
+}
+
+// bucket walks nested directories along keys starting at the namespace directory.
+func (tx *dirTx) bucket(keys []string) (string, error) {
+	dir := tx.dir
+	for _, key := range keys {
+		dir = filepath.Join(dir, encodeName(key))
+		fi, err := os.Stat(dir)
+		if os.IsNotExist(err) {
+			return "", ErrNotFound
+		}
+		if err != nil {
+			return "", err
+		}
+		if !fi.IsDir() {
+			return "", ErrPathIsValue
+		}
+	}
+
+	return dir, nil
+}
+
+// mkdirs walks nested directories along keys creating those that do not exist.
+func (tx *dirTx) mkdirs(keys []string) (string, error) {
+	dir := tx.dir
+	for _, key := range keys {
+		parent := dir
+		dir = filepath.Join(dir, encodeName(key))
+		err := os.Mkdir(dir, 0755)
+		switch {
+		case err == nil:
+			if err := syncDir(parent); err != nil {
+				return "", err
+			}
+		case os.IsExist(err):
+			fi, err := os.Stat(dir)
+			if err != nil {
+				return "", err
+			}
+			if !fi.IsDir() {
+				return "", ErrPathIsValue
+			}
+		default:
+			return "", err
+		}
+	}
+
+	return dir, nil
+}
+
+// Set sets a value at a key creating directories along the path.
+func (tx *dirTx) Set(key string, val []byte) error {
+	if err := tx.ctx.Err(); err != nil {
+		return keyError("set", key, err)
+	}
+
+	keys, err := splitKey(key)
+	if err != nil {
+		return keyError("set", key, err)
+	}
+
+	if len(keys) == 0 {
+		return keyError("set", key, ErrEmptyKey)
+	}
+
+	if val == nil {
+		return keyError("set", key, ErrNilValue)
+	}
+
+	dir, err := tx.mkdirs(keys[:len(keys)-1])
+	if err != nil {
+		return keyError("set", key, err)
+	}
+
+	p := filepath.Join(dir, encodeName(keys[len(keys)-1]))
+	if fi, err := os.Stat(p); err == nil && fi.IsDir() {
+		return keyError("set", key, ErrIsBucket)
+	}
+
+	return keyError("set", key, writeFile(p, val))
+}
+
+// Get gets a value from a key.
+func (tx *dirTx) Get(key string) ([]byte, error) {
+	if err := tx.ctx.Err(); err != nil {
+		return nil, keyError("get", key, err)
+	}
+
+	keys, err := splitKey(key)
+	if err != nil {
+		return nil, keyError("get", key, err)
+	}
+
+	if len(keys) == 0 {
+		return nil, keyError("get", key, ErrEmptyKey)
+	}
+
+	dir, err := tx.bucket(keys[:len(keys)-1])
+	if err != nil {
+		return nil, keyError("get", key, err)
+	}
+
+	p := filepath.Join(dir, encodeName(keys[len(keys)-1]))
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return nil, keyError("get", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, keyError("get", key, err)
+	}
+
+	if fi.IsDir() {
+		return nil, keyError("get", key, ErrIsBucket)
+	}
+
+	val, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, keyError("get", key, ErrNotFound)
+	}
+
+	return val, keyError("get", key, err)
+}
+
+// Delete deletes a key or a directory along with everything in it.
+func (tx *dirTx) Delete(key string) error {
+	if err := tx.ctx.Err(); err != nil {
+		return keyError("delete", key, err)
+	}
+
+	keys, err := splitKey(key)
+	if err != nil {
+		return keyError("delete", key, err)
+	}
+
+	if len(keys) == 0 {
+		return keyError("delete", key, ErrEmptyKey)
+	}
+
+	dir, err := tx.bucket(keys[:len(keys)-1])
+	if err != nil {
+		return keyError("delete", key, err)
+	}
+
+	name := encodeName(keys[len(keys)-1])
+	fi, err := os.Lstat(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return keyError("delete", key, ErrNotFound)
+	}
+	if err != nil {
+		return keyError("delete", key, err)
+	}
+
+	if fi.IsDir() {
+		err = removeTree(dir, name)
+	} else {
+		err = os.Remove(filepath.Join(dir, name))
+	}
+	if err != nil {
+		return keyError("delete", key, err)
+	}
+
+	return keyError("delete", key, syncDir(dir))
+}
+
+// Enumerate lists all keys under a key.
+func (tx *dirTx) Enumerate(key string) ([]string, error) {
+	var list []string
+	if err := tx.walk(key, func(key string, _ []byte) error {
+		list = append(list, key)
+		return nil
+	}); err != nil {
+		return nil, keyError("enumerate", key, err)
+	}
+
+	return list, nil
+}
+
+// walk calls f for every leaf under the directory key points to in sorted order.
+func (tx *dirTx) walk(key string, f func(key string, val []byte) error) error {
+	dir, _, err := tx.bucketAt(key)
+	if err != nil {
+		return err
+	}
+
+	return scanRange(tx.ctx, newDirCursor(dir), key, nil, nil, false, f)
+}
+
+// writeFile replaces the file at p with val. val is written to a temporary
+// file next to p which is synced and renamed over p, so that readers see
+// either the old or the new value, and the directory is synced so that the
+// rename survives a crash.
+func writeFile(p string, val []byte) error {
+	dir := filepath.Dir(p)
+	f, err := ioutil.TempFile(dir, tmpPrefix)
+	if err != nil {
+		return err
+	}
+	// the temporary file is gone once renamed, so this only cleans up failures.
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(val); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Chmod(0644); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.Name(), p); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// removeTree removes the directory name within dir. It is renamed out of the
+// way first, so that the bucket disappears at once even if removing its
+// contents is interrupted.
+func removeTree(dir, name string) error {
+	tmp := filepath.Join(dir, delPrefix+name)
+	// clean up after an earlier removal that was interrupted.
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+
+	if err := os.Rename(filepath.Join(dir, name), tmp); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(tmp)
+}
+
+// syncDir flushes changes to the entries of dir to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// nameReserved holds the characters escaped in file names: the percent sign
+// escaping them, slashes, and characters Windows does not allow in file
+// names, among them the backslash separating paths there.
+const nameReserved = `%/\:*?"<>|`
+
+// deviceNames are names Windows reserves for devices with any extension.
+var deviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// encodeName encodes a segment as a file name that stays within its
+// directory on every platform. Reserved characters, trailing dots and spaces,
+// which Windows drops, and the first character of device names are escaped
+// as %XX. Other characters are left as they are so that files remain easy
+// to find by key.
+func encodeName(seg string) string {
+	device := deviceNames[strings.ToUpper(strings.TrimRight(strings.SplitN(seg, ".", 2)[0], " "))]
+
+	var name strings.Builder
+	for i := 0; i < len(seg); i++ {
+		c := seg[i]
+		if strings.IndexByte(nameReserved, c) >= 0 ||
+			(i == 0 && device) ||
+			((c == '.' || c == ' ') && strings.TrimRight(seg[i:], ". ") == "") {
+			fmt.Fprintf(&name, "%%%02X", c)
+			continue
+		}
+		name.WriteByte(c)
+	}
+
+	return name.String()
+}
+
+// decodeName reverses encodeName, reporting false for names encodeName
+// never returns, such as those of temporary files.
+func decodeName(name string) (string, bool) {
+	var seg strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != '%' {
+			seg.WriteByte(name[i])
+			continue
+		}
+
+		if i+2 >= len(name) {
+			return "", false
+		}
+
+		c, err := strconv.ParseUint(name[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", false
+		}
+		seg.WriteByte(byte(c))
+		i += 2
+	}
+
+	// only the escapes encodeName makes decode, so that a key maps onto a
+	// single file name.
+	if encodeName(seg.String()) != name {
+		return "", false
+	}
+
+	return seg.String(), true
+}
+
+// dirEntry is a child of a directory as seen by a dirCursor.
+type dirEntry struct {
+	name string
+	leaf bool
+}
+
+// dirCursor implements cursor over the entries of a directory sorted by
+// the segments they decode to.
+type dirCursor struct {
+	dir     string
+	entries []dirEntry
+	i       int
+}
+
+// newDirCursor lists dir, which is taken to be empty if it cannot be read,
+// such as when it was removed by another process.
+func newDirCursor(dir string) *dirCursor {
+	c := &dirCursor{dir: dir}
+
+	fis, _ := ioutil.ReadDir(dir)
+	for _, fi := range fis {
+		if name, ok := decodeName(fi.Name()); ok {
+			c.entries = append(c.entries, dirEntry{name: name, leaf: !fi.IsDir()})
+		}
+	}
+	sort.Slice(c.entries, func(i, j int) bool { return c.entries[i].name < c.entries[j].name })
+
+	return c
+}
+
+// at moves the cursor to the entry at index i.
+func (c *dirCursor) at(i int) (string, bool) {
+	c.i = i
+	if i < 0 || i >= len(c.entries) {
+		return "", false
+	}
+
+	return c.entries[i].name, c.entries[i].leaf
+}
+
+func (c *dirCursor) First() (string, bool) { return c.at(0) }
+func (c *dirCursor) Last() (string, bool)  { return c.at(len(c.entries) - 1) }
+func (c *dirCursor) Next() (string, bool)  { return c.at(c.i + 1) }
+func (c *dirCursor) Prev() (string, bool)  { return c.at(c.i - 1) }
+
+func (c *dirCursor) Seek(name string) (string, bool) {
+	return c.at(sort.Search(len(c.entries), func(i int) bool { return c.entries[i].name >= name }))
+}
+
+// Value reads the file of the current entry, returning nil if it cannot be read.
+func (c *dirCursor) Value() []byte {
+	val, _ := ioutil.ReadFile(c.path())
+	return val
+}
+
+func (c *dirCursor) Bucket() cursor {
+	return newDirCursor(c.path())
+}
+
+// path returns the path of the current entry.
+func (c *dirCursor) path() string {
+	return filepath.Join(c.dir, encodeName(c.entries[c.i].name))
+}
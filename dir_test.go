@@ -0,0 +1,209 @@
+package kv_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sdeoras/kv"
+	"github.com/sdeoras/kv/kvtest"
+)
+
+// newDirKv opens a dir kv in a fresh temp dir returning the directory of the
+// namespace along with a func to close and remove it.
+func newDirKv(t *testing.T) (kv.KV, string, func()) {
+	root, err := ioutil.TempDir("", "kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, closeKv, err := kv.NewDirKv(root, nameSpace)
+	if err != nil {
+		_ = os.RemoveAll(root)
+		t.Fatal(err)
+	}
+
+	return db, filepath.Join(root, nameSpace), func() {
+		_ = closeKv()
+		_ = os.RemoveAll(root)
+	}
+}
+
+func TestDirKv(t *testing.T) {
+	kvtest.RunConformance(t, func() (kv.KV, func()) {
+		db, _, done := newDirKv(t)
+		return db, done
+	})
+}
+
+func TestDirKv_Files(t *testing.T) {
+	db, dir, done := newDirKv(t)
+	defer done()
+
+	if err := db.Set(`/a/b\/c/100%`, []byte("val")); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "a", "b%2Fc", "100%25"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "val" {
+		t.Fatal("expected val, got:", string(b))
+	}
+
+	// files that do not decode to a key, such as leftover temporary files, are skipped.
+	if err := ioutil.WriteFile(filepath.Join(dir, "a", "%tmp-1"), []byte("tmp"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// files written by hand are picked up as keys.
+	if err := ioutil.WriteFile(filepath.Join(dir, "a", "otherKey"), []byte("otherVal"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := db.Enumerate("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 2 || keys[0] != `/a/b\/c/100%` || keys[1] != "/a/otherKey" {
+		t.Fatal("unexpected keys:", keys)
+	}
+
+	if err := db.Delete("/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "a")); !os.IsNotExist(err) {
+		t.Fatal("expected directory to be removed, got:", err)
+	}
+}
+
+func TestDirKv_FileNames(t *testing.T) {
+	db, dir, done := newDirKv(t)
+	defer done()
+
+	// segments that would escape the namespace or be changed by Windows are
+	// escaped in file names.
+	tests := []struct {
+		key, name string
+	}{
+		{`/..\\x`, `..%5Cx`},
+		{`/a:b*c?d"e<f>g|h`, `a%3Ab%2Ac%3Fd%22e%3Cf%3Eg%7Ch`},
+		{"/x. .", "x%2E%20%2E"},
+		{"/con.txt", "%63on.txt"},
+		{"/COM1", "%43OM1"},
+		{"/console", "console"},
+		{"/a.b", "a.b"},
+	}
+
+	for _, test := range tests {
+		if err := db.Set(test.key, []byte("val")); err != nil {
+			t.Fatal(err)
+		}
+
+		if b, err := ioutil.ReadFile(filepath.Join(dir, test.name)); err != nil || string(b) != "val" {
+			t.Fatalf("%s: expected val in %s, got: %q %v", test.key, test.name, b, err)
+		}
+	}
+
+	keys, err := db.Enumerate("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != len(tests) {
+		t.Fatal("unexpected keys:", keys)
+	}
+
+	for _, test := range tests {
+		if _, err := db.Get(test.key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// names escaping characters that need no escaping do not decode to keys.
+	if err := ioutil.WriteFile(filepath.Join(dir, "%61"), []byte("val"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys, err := db.Enumerate("/"); err != nil || len(keys) != len(tests) {
+		t.Fatal("unexpected keys:", keys, err)
+	}
+}
+
+func TestDirKv_Closed(t *testing.T) {
+	root, err := ioutil.TempDir("", "kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	db, closeKv, err := kv.NewDirKv(root, nameSpace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := closeKv(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Get("/a/b/c/myKey"); !errors.Is(err, kv.ErrClosed) {
+		t.Fatal("expected ErrClosed, got:", err)
+	}
+}
+
+func TestDirKv_SharedRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// kvs opened on the same namespace within a process share a lock, so
+	// that only one of them creates a key.
+	var dbs []kv.Conditional
+	for i := 0; i < 4; i++ {
+		db, closeKv, err := kv.NewDirKv(root, nameSpace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer closeKv()
+		dbs = append(dbs, db.(kv.Conditional))
+	}
+
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("/k/%d", i)
+		errs := make(chan error, len(dbs))
+		for _, db := range dbs {
+			go func(db kv.Conditional) { errs <- db.SetIfAbsent(key, []byte("val")) }(db)
+		}
+
+		created := 0
+		for range dbs {
+			switch err := <-errs; {
+			case err == nil:
+				created++
+			case !errors.Is(err, kv.ErrExists):
+				t.Fatal(err)
+			}
+		}
+
+		if created != 1 {
+			t.Fatalf("%s: expected a single create, got: %d", key, created)
+		}
+	}
+}
+
+func TestDirKv_InvalidNamespace(t *testing.T) {
+	for _, ns := range []string{"", "..", "__reserved"} {
+		if _, _, err := kv.NewDirKv(os.TempDir(), ns); !errors.Is(err, kv.ErrInvalidNamespace) {
+			t.Fatalf("namespace %q: expected ErrInvalidNamespace, got: %v", ns, err)
+		}
+	}
+}
@@ -2,169 +2,1487 @@ package kv
 
 import (
 	"context"
-	"fmt"
-	"path/filepath"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"cloud.google.com/go/datastore"
+	"google.golang.org/api/iterator"
 )
 
+var (
+	_ KVContext       = (*dsKv)(nil)
+	_ Txn             = (*dsKv)(nil)
+	_ Versioned       = (*dsKv)(nil)
+	_ Conditional     = (*dsKv)(nil)
+	_ Iterator        = (*dsKv)(nil)
+	_ Pager           = (*dsKv)(nil)
+	_ Scanner         = (*dsKv)(nil)
+	_ ValueEnumerator = (*dsKv)(nil)
+	_ Lister          = (*dsKv)(nil)
+	_ Stater          = (*dsKv)(nil)
+	_ Expirer         = (*dsKv)(nil)
+	_ Watcher         = (*dsKv)(nil)
+	_ Copier          = (*dsKv)(nil)
+	_ Expirer         = (*dsTx)(nil)
+	_ revisionTx      = (*dsTx)(nil)
+	_ Store           = (*dsStore)(nil)
+)
+
+// maxBatch is the maximum number of entities datastore accepts in a single call.
+const maxBatch = 500
+
+// dsStore implements Store over a datastore client, keeping every namespace
+// in an entity kind.
+type dsStore struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	client *datastore.Client
+	// now tells the current time when expiring keys.
+	now func() time.Time
+	// pollInterval is how often Watch polls for changes.
+	pollInterval time.Duration
+	// dropped maps namespaces being watched to a channel closed once they
+	// are dropped or renamed, and is guarded by mu.
+	dropped map[string]chan struct{}
+	// closed is set to 1 once the client has been closed.
+	closed int32
+}
+
 type dsKv struct {
-	mu        sync.Mutex
-	ctx       context.Context
+	*dsStore
 	nameSpace string
-	client    *datastore.Client
 }
 
 type Buffer struct {
 	Valid bool
 	Value []byte
+	// Expires is the time a leaf expires at, or zero if it does not expire.
+	Expires time.Time `datastore:",noindex,omitempty"`
+	// Revision is a random revision a leaf was last written at. Leaves written
+	// before revisions were kept have none and are versioned by content.
+	Revision string `datastore:",noindex,omitempty"`
+}
+
+// newRevision returns a random revision. Datastore has no counter to draw
+// revisions from within a transaction, so they are unique but unordered.
+func newRevision() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// dsTx implements Tx over a datastore transaction, or directly over the client
+// when tx is nil. Datastore transactions do not observe their own writes, so
+// pending writes are tracked and overlaid on reads.
+type dsTx struct {
+	ctx      context.Context
+	d        *dsKv
+	tx       *datastore.Transaction
+	writable bool
+	// pending maps entity names written in this transaction to their
+	// buffers, with a nil buffer marking a deleted entity.
+	pending map[string]*Buffer
+	// now is the time at which the transaction started, which decides
+	// which keys have expired.
+	now time.Time
+	// expired collects keys of stored entities found to have expired,
+	// which can then be reclaimed.
+	expired []*datastore.Key
+}
+
+func newDataStoreKv(ctx context.Context, projectID, nameSpace string, opts ...Option) (*dsKv, func() error, error) {
+	s, f, err := newDataStoreStore(ctx, projectID, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d, err := s.namespace(nameSpace)
+	if err != nil {
+		_ = f()
+		return nil, nil, err
+	}
+
+	return d, f, nil
 }
 
-func newDataStoreKv(ctx context.Context, projectID, nameSpace string) (*dsKv, func() error, error) {
+func newDataStoreStore(ctx context.Context, projectID string, opts ...Option) (*dsStore, func() error, error) {
+	o := newOptions(opts)
 	client, err := datastore.NewClient(ctx, projectID)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	s := &dsStore{
+		ctx:          ctx,
+		client:       client,
+		now:          o.now,
+		pollInterval: o.pollInterval,
+		dropped:      make(map[string]chan struct{}),
+	}
+
 	f := func() error {
+		atomic.StoreInt32(&s.closed, 1)
 		return client.Close()
 	}
 
-	return &dsKv{
-		ctx:       ctx,
-		nameSpace: nameSpace,
-		client:    client,
-	}, f, nil
+	return s, f, nil
 }
 
-func (d *dsKv) Get(key string) ([]byte, error) {
-	if len(key) == 0 {
-		return nil, fmt.Errorf("key cannot be empty")
+// Namespace returns a KV over namespace ns. Kinds only exist while they
+// hold entities, so a namespace is listed after its first write.
+func (s *dsStore) Namespace(ns string) (KV, error) {
+	return s.namespace(ns)
+}
+
+func (s *dsStore) namespace(ns string) (*dsKv, error) {
+	if err := checkNamespace(ns); err != nil {
+		return nil, namespaceError("open", ns, err)
 	}
 
-	keys := splitKey(key, d.nameSpace)
+	return &dsKv{dsStore: s, nameSpace: ns}, nil
+}
 
-	var parent *datastore.Key
-	var child *datastore.Key
-	for i := range keys {
-		child = datastore.NameKey(d.nameSpace, filepath.Join(keys[:i+1]...), parent)
-		if err := d.client.Get(d.ctx, child, new(Buffer)); err != nil {
-			return nil, err
-		}
-		parent = child
+// ListNamespaces lists kinds holding entities, skipping kinds reserved
+// by datastore.
+func (s *dsStore) ListNamespaces() ([]string, error) {
+	if err := s.check(s.ctx); err != nil {
+		return nil, err
 	}
 
-	b := new(Buffer)
-	if err := d.client.Get(d.ctx, child, b); err != nil {
+	dsKeys, err := s.client.GetAll(s.ctx, datastore.NewQuery("__kind__").KeysOnly(), nil)
+	if err != nil {
 		return nil, err
 	}
 
-	if !b.Valid {
-		return nil, fmt.Errorf("invalid key. key points to a bucket and not a value")
+	var names []string
+	for _, k := range dsKeys {
+		if !strings.HasPrefix(k.Name, "__") {
+			names = append(names, k.Name)
+		}
 	}
+	sort.Strings(names)
 
-	return b.Value, nil
+	return names, nil
 }
 
-func (d *dsKv) Set(key string, val []byte) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// DropNamespace deletes every entity of a kind in batches. It is not atomic,
+// so a failed drop may leave some keys behind.
+func (s *dsStore) DropNamespace(ns string) error {
+	if err := checkNamespace(ns); err != nil {
+		return namespaceError("drop", ns, err)
+	}
 
-	if len(key) == 0 {
-		return fmt.Errorf("cannot set empty key")
+	if err := s.check(s.ctx); err != nil {
+		return namespaceError("drop", ns, err)
 	}
 
-	if val == nil {
-		return fmt.Errorf("val cannot be nil, use zero value instead")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dsKeys, err := s.client.GetAll(s.ctx, datastore.NewQuery(ns).KeysOnly(), nil)
+	if err != nil {
+		return namespaceError("drop", ns, err)
 	}
 
-	var parent *datastore.Key
-	var child *datastore.Key
+	if len(dsKeys) == 0 {
+		return namespaceError("drop", ns, ErrNotFound)
+	}
 
-	keys := splitKey(key, d.nameSpace)
-	for i := range keys {
-		i := i
-		child = datastore.NameKey(d.nameSpace, filepath.Join(keys[:i+1]...), parent)
-		err := d.client.Get(d.ctx, child, new(Buffer))
-		switch err {
-		// if the key does not exist, then put one
-		case datastore.ErrNoSuchEntity, datastore.ErrInvalidKey:
-			child, err = d.client.Put(d.ctx, child, new(Buffer))
-			if err != nil {
-				return err
-			}
-		case nil:
-		default:
-			return err
+	s.drop(ns)
+	return namespaceError("drop", ns, s.deleteAll(dsKeys))
+}
+
+// RenameNamespace copies every entity of a kind into a new kind and deletes
+// the old ones afterwards. It is not atomic, so keys may be visible under
+// both names while it runs, and a failed rename may leave both partially
+// populated.
+func (s *dsStore) RenameNamespace(old, new string) error {
+	if err := checkNamespace(old); err != nil {
+		return namespaceError("rename", old, err)
+	}
+
+	if err := checkNamespace(new); err != nil {
+		return namespaceError("rename", new, err)
+	}
+
+	if err := s.check(s.ctx); err != nil {
+		return namespaceError("rename", old, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.client.GetAll(s.ctx, datastore.NewQuery(new).KeysOnly().Limit(1), nil)
+	if err != nil {
+		return namespaceError("rename", new, err)
+	}
+
+	if len(existing) > 0 {
+		return namespaceError("rename", new, ErrExists)
+	}
+
+	var bufs []*Buffer
+	dsKeys, err := s.client.GetAll(s.ctx, datastore.NewQuery(old), &bufs)
+	if err != nil {
+		return namespaceError("rename", old, err)
+	}
+
+	if len(dsKeys) == 0 {
+		return namespaceError("rename", old, ErrNotFound)
+	}
+
+	d := &dsKv{dsStore: s, nameSpace: new}
+	newKeys := make([]*datastore.Key, len(dsKeys))
+	for i, k := range dsKeys {
+		newKeys[i] = d.dsKey(k.Name)
+	}
+
+	for len(newKeys) > 0 {
+		n := len(newKeys)
+		if n > maxBatch {
+			n = maxBatch
 		}
-		parent = child
+
+		if _, err := s.client.PutMulti(s.ctx, newKeys[:n], bufs[:n]); err != nil {
+			return namespaceError("rename", new, err)
+		}
+
+		newKeys, bufs = newKeys[n:], bufs[n:]
 	}
 
-	b := new(Buffer)
-	b.Value = val
-	b.Valid = true
-	_, err := d.client.Put(d.ctx, child, b)
-	return err
+	s.drop(old)
+	return namespaceError("rename", old, s.deleteAll(dsKeys))
 }
 
-func (d *dsKv) Delete(key string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// droppedChan returns a channel closed once namespace ns is dropped or
+// renamed through this store.
+func (s *dsStore) droppedChan(ns string) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	keys, err := d.enumerate(key)
-	if err != nil {
-		return err
+	c, ok := s.dropped[ns]
+	if !ok {
+		c = make(chan struct{})
+		s.dropped[ns] = c
+	}
+
+	return c
+}
+
+// drop ends watchers of namespace ns, so that they are not fed by a
+// namespace created again under its name. s.mu must be held.
+func (s *dsStore) drop(ns string) {
+	if c, ok := s.dropped[ns]; ok {
+		close(c)
+		delete(s.dropped, ns)
 	}
+}
+
+// deleteAll deletes entities in batches outside of a transaction.
+func (s *dsStore) deleteAll(dsKeys []*datastore.Key) error {
+	for len(dsKeys) > 0 {
+		n := len(dsKeys)
+		if n > maxBatch {
+			n = maxBatch
+		}
 
-	for _, key := range keys {
-		if err := d.client.Delete(context.Background(), key); err != nil {
+		if err := s.client.DeleteMulti(s.ctx, dsKeys[:n]); err != nil {
 			return err
 		}
+
+		dsKeys = dsKeys[n:]
 	}
 
 	return nil
 }
 
-func (d *dsKv) enumerate(key string) ([]*datastore.Key, error) {
-	if len(key) == 0 {
-		return nil, fmt.Errorf("key cannot be empty")
+// check returns an error if the client has been closed or ctx is done.
+func (d *dsStore) check(ctx context.Context) error {
+	if atomic.LoadInt32(&d.closed) != 0 {
+		return ErrClosed
 	}
 
-	keys := splitKey(key, d.nameSpace)
+	return ctx.Err()
+}
+
+// dsKeys returns datastore keys for every entity along keys.
+func (d *dsKv) dsKeys(keys []string) []*datastore.Key {
+	dsKeys := make([]*datastore.Key, len(keys))
 
 	var parent *datastore.Key
-	var child *datastore.Key
 	for i := range keys {
-		child = datastore.NameKey(d.nameSpace, filepath.Join(keys[:i+1]...), parent)
-		if err := d.client.Get(d.ctx, child, new(Buffer)); err != nil {
-			return nil, err
+		dsKeys[i] = datastore.NameKey(d.nameSpace, Key(keys[:i+1]).path(), parent)
+		parent = dsKeys[i]
+	}
+
+	return dsKeys
+}
+
+// dsKey returns the datastore key of the entity for an entity name.
+func (d *dsKv) dsKey(name string) *datastore.Key {
+	dsKeys := d.dsKeys(splitPath(name))
+	return dsKeys[len(dsKeys)-1]
+}
+
+// newTx returns a Tx that operates directly on the client.
+func (d *dsKv) newTx(ctx context.Context) *dsTx {
+	return &dsTx{ctx: ctx, d: d, writable: true, now: d.now()}
+}
+
+// update runs f in a datastore transaction, which is retried on contention.
+func (d *dsKv) update(ctx context.Context, f func(tx *dsTx) error) error {
+	if err := d.check(ctx); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.client.RunInTransaction(ctx, func(t *datastore.Transaction) error {
+		return f(&dsTx{ctx: ctx, d: d, tx: t, writable: true, pending: make(map[string]*Buffer), now: d.now()})
+	})
+
+	return err
+}
+
+// view runs f in a read-only datastore transaction.
+func (d *dsKv) view(ctx context.Context, f func(tx *dsTx) error) error {
+	if err := d.check(ctx); err != nil {
+		return err
+	}
+
+	_, err := d.client.RunInTransaction(ctx, func(t *datastore.Transaction) error {
+		return f(&dsTx{ctx: ctx, d: d, tx: t, pending: make(map[string]*Buffer), now: d.now()})
+	}, datastore.ReadOnly)
+
+	return err
+}
+
+// Update runs f in a datastore transaction. f may be called several times
+// if the transaction is retried on contention. Datastore limits a transaction
+// to 25 entity groups, where all keys sharing their first path element form
+// one entity group, and to 500 written entities.
+func (d *dsKv) Update(f func(tx Tx) error) error {
+	return d.update(d.ctx, func(tx *dsTx) error { return f(tx) })
+}
+
+// View runs f in a read-only datastore transaction.
+func (d *dsKv) View(f func(tx Tx) error) error {
+	return d.view(d.ctx, func(tx *dsTx) error { return f(tx) })
+}
+
+func (d *dsKv) Get(key string) ([]byte, error) {
+	return d.GetContext(d.ctx, key)
+}
+
+func (d *dsKv) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := d.check(ctx); err != nil {
+		return nil, keyError("get", key, err)
+	}
+
+	tx := d.newTx(ctx)
+	val, err := tx.Get(key)
+	if len(tx.expired) > 0 {
+		d.reclaim(ctx, tx.expired)
+	}
+
+	return val, err
+}
+
+// reclaim deletes entities found to have expired. Datastore has no janitor,
+// so expired keys are reclaimed lazily as they are read. Entities are checked
+// again within a transaction in case they have been set since.
+func (d *dsKv) reclaim(ctx context.Context, dsKeys []*datastore.Key) {
+	_ = d.update(ctx, func(tx *dsTx) error {
+		if _, err := tx.getMulti(dsKeys); err != nil {
+			return err
 		}
-		parent = child
+
+		return tx.deleteMulti(tx.expired)
+	})
+}
+
+func (d *dsKv) Set(key string, val []byte) error {
+	return d.SetContext(d.ctx, key, val)
+}
+
+// SetContext sets a value in a transaction so that concurrent writers
+// cannot interleave between checking and creating buckets along the path.
+func (d *dsKv) SetContext(ctx context.Context, key string, val []byte) error {
+	return keyError("set", key, d.update(ctx, func(tx *dsTx) error {
+		return tx.Set(key, val)
+	}))
+}
+
+func (d *dsKv) Delete(key string) error {
+	return d.DeleteContext(d.ctx, key)
+}
+
+// DeleteContext deletes a key or a bucket along with everything in it.
+// Buckets may hold more entities than a transaction allows, so deletion
+// happens in batches outside of a transaction.
+func (d *dsKv) DeleteContext(ctx context.Context, key string) error {
+	if err := d.check(ctx); err != nil {
+		return keyError("delete", key, err)
 	}
 
-	q := datastore.NewQuery("test").Ancestor(child).KeysOnly()
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	return d.client.GetAll(context.Background(), q, nil)
+	return d.newTx(ctx).Delete(key)
 }
 
 func (d *dsKv) Enumerate(key string) ([]string, error) {
-	keys, err := d.enumerate(key)
+	return d.EnumerateContext(d.ctx, key)
+}
+
+func (d *dsKv) EnumerateContext(ctx context.Context, key string) ([]string, error) {
+	if err := d.check(ctx); err != nil {
+		return nil, keyError("enumerate", key, err)
+	}
+
+	return d.newTx(ctx).Enumerate(key)
+}
+
+// EnumerateValues lists all keys under a key along with their values
+// using a single ancestor query.
+func (d *dsKv) EnumerateValues(key string) ([]Pair, error) {
+	if err := d.check(d.ctx); err != nil {
+		return nil, keyError("enumerate", key, err)
+	}
+
+	return d.newTx(d.ctx).EnumerateValues(key)
+}
+
+// List lists the immediate children of a bucket.
+func (d *dsKv) List(prefix string) ([]Entry, error) {
+	if err := d.check(d.ctx); err != nil {
+		return nil, keyError("list", prefix, err)
+	}
+
+	return d.newTx(d.ctx).List(prefix)
+}
+
+// Stat describes a key.
+func (d *dsKv) Stat(key string) (Info, error) {
+	if err := d.check(d.ctx); err != nil {
+		return Info{}, keyError("stat", key, err)
+	}
+
+	return d.newTx(d.ctx).Stat(key)
+}
+
+// Exists reports whether a key exists.
+func (d *dsKv) Exists(key string) bool {
+	info, err := d.Stat(key)
+	return err == nil && info.Exists
+}
+
+// SetWithTTL sets a value that expires after ttl. Expired keys are
+// reclaimed lazily when read.
+func (d *dsKv) SetWithTTL(key string, val []byte, ttl time.Duration) error {
+	if err := checkTTL(ttl); err != nil {
+		return keyError("set", key, err)
+	}
+
+	return keyError("set", key, d.update(d.ctx, func(tx *dsTx) error {
+		return tx.SetWithTTL(key, val, ttl)
+	}))
+}
+
+// TTL returns the time left before a key expires.
+func (d *dsKv) TTL(key string) (time.Duration, error) {
+	if err := d.check(d.ctx); err != nil {
+		return 0, keyError("ttl", key, err)
+	}
+
+	return d.newTx(d.ctx).TTL(key)
+}
+
+// Watch reports changes to keys under prefix by polling, since Datastore has
+// no change notifications. Changes are found by comparing snapshots of keys
+// under prefix, so they are reported in key order rather than the order they
+// were made in, and a key changed back before the next poll is not reported.
+// Keys that expire are reported as deleted.
+func (d *dsKv) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	if err := d.check(ctx); err != nil {
+		return nil, keyError("watch", prefix, err)
+	}
+
+	prev, err := d.snapshot(prefix)
 	if err != nil {
-		return nil, err
+		return nil, keyError("watch", prefix, err)
 	}
 
-	var outKeys []string
-	for _, key := range keys {
-		b := new(Buffer)
-		if err := d.client.Get(context.Background(), key, b); err != nil {
-			return nil, err
-		} else {
-			if b.Valid {
-				outKeys = append(outKeys, key.Name)
+	out := make(chan Event)
+	go d.poll(ctx, prefix, prev, d.droppedChan(d.nameSpace), out)
+
+	return out, nil
+}
+
+// poll sends changes to keys under prefix since prev to out every poll
+// interval until ctx is done, the client is closed or the namespace is
+// dropped. Failed polls are retried on the next tick.
+func (d *dsKv) poll(ctx context.Context, prefix string, prev map[string][]byte, dropped <-chan struct{}, out chan<- Event) {
+	defer close(out)
+
+	t := time.NewTicker(d.pollInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-dropped:
+			return
+		case <-t.C:
+		}
+
+		if err := d.check(ctx); err != nil {
+			return
+		}
+
+		next, err := d.snapshot(prefix)
+		if err != nil {
+			continue
+		}
+
+		for _, e := range diffPairs(prev, next) {
+			select {
+			case out <- e:
+			case <-ctx.Done():
+				return
+			case <-dropped:
+				return
 			}
 		}
+		prev = next
 	}
+}
 
-	return outKeys, nil
+// snapshot maps keys under prefix, or prefix itself if it is a leaf, to their
+// values. A prefix that does not exist has no keys.
+func (d *dsKv) snapshot(prefix string) (map[string][]byte, error) {
+	pairs, err := d.EnumerateValues(prefix)
+	if errors.Is(err, ErrPathIsValue) {
+		var val []byte
+		val, err = d.Get(prefix)
+		pairs = []Pair{{Key: prefix, Value: val}}
+	}
+
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrPathIsValue):
+		pairs = nil
+	case err != nil:
+		return nil, err
+	}
+
+	m := make(map[string][]byte, len(pairs))
+	for _, p := range pairs {
+		m[splitPath(p.Key).String()] = p.Value
+	}
+
+	return m, nil
+}
+
+// GetWithVersion gets a value along with its version.
+func (d *dsKv) GetWithVersion(key string) ([]byte, Version, error) {
+	if err := d.check(d.ctx); err != nil {
+		return nil, "", keyError("get", key, err)
+	}
+
+	return getWithVersion(d.newTx(d.ctx), key)
+}
+
+// SetIfVersion sets a value if key is still at version.
+func (d *dsKv) SetIfVersion(key string, val []byte, version Version) error {
+	return keyError("set", key, d.update(d.ctx, func(tx *dsTx) error {
+		return setIfVersion(tx, key, val, version)
+	}))
+}
+
+// DeleteIfVersion deletes a key if it is still at version.
+func (d *dsKv) DeleteIfVersion(key string, version Version) error {
+	return keyError("delete", key, d.update(d.ctx, func(tx *dsTx) error {
+		return deleteIfVersion(tx, key, version)
+	}))
+}
+
+// SetIfAbsent sets a value if key does not exist.
+func (d *dsKv) SetIfAbsent(key string, val []byte) error {
+	return keyError("set", key, d.update(d.ctx, func(tx *dsTx) error {
+		return setIfAbsent(tx, key, val)
+	}))
+}
+
+// Copy copies the leaf or bucket at src to dst. See copyOrMove.
+func (d *dsKv) Copy(src, dst string) error {
+	return keyError("copy", src, d.copyOrMove(src, dst, false))
+}
+
+// Move moves the leaf or bucket at src to dst. See copyOrMove.
+func (d *dsKv) Move(src, dst string) error {
+	return keyError("move", src, d.copyOrMove(src, dst, true))
+}
+
+// copyOrMove copies src to dst in a single transaction if the entities it
+// writes fit within the limit datastore sets on a transaction. Larger trees
+// are copied entity by entity outside of a transaction, deleting src only
+// once it has been copied in full.
+func (d *dsKv) copyOrMove(src, dst string, move bool) error {
+	if err := d.check(d.ctx); err != nil {
+		return err
+	}
+
+	dsKeys, _, err := d.newTx(d.ctx).enumerate(src, true, true)
+	if err != nil {
+		return err
+	}
+
+	keys, err := splitKey(dst)
+	if err != nil {
+		return err
+	}
+
+	n := len(dsKeys) + len(keys)
+	if move {
+		n += len(dsKeys)
+	}
+
+	if n <= maxBatch {
+		return d.update(d.ctx, func(tx *dsTx) error {
+			return copyKeys(tx, src, dst, move)
+		})
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return copyKeys(d.newTx(d.ctx), src, dst, move)
+}
+
+// Replace sets a value if key exists.
+func (d *dsKv) Replace(key string, val []byte) error {
+	return keyError("set", key, d.update(d.ctx, func(tx *dsTx) error {
+		return replace(tx, key, val)
+	}))
+}
+
+// Iterate calls f for every key under prefix along with its value in the
+// order defined by Key.Less, loading them from a single ancestor query run
+// in a read-only transaction.
+func (d *dsKv) Iterate(ctx context.Context, prefix string, f func(key string, val []byte) error) error {
+	return keyError("iterate", prefix, d.view(ctx, func(tx *dsTx) error {
+		return tx.walk(prefix, f)
+	}))
+}
+
+// IterateKeys calls f for every key under prefix in the order defined by
+// Key.Less, loading them from a single ancestor query run in a read-only
+// transaction. Entities are fetched in full rather than
+// keys only, since expiry times are not indexed and tell which keys are left.
+func (d *dsKv) IterateKeys(ctx context.Context, prefix string, f func(key string) error) error {
+	return keyError("iterate", prefix, d.view(ctx, func(tx *dsTx) error {
+		return tx.walk(prefix, func(key string, _ []byte) error { return f(key) })
+	}))
+}
+
+// EnumeratePage lists a page of keys under prefix in the order defined by
+// Key.Less. Every page loads the bucket, since datastore orders and resumes
+// queries by escaped names, which sort differently.
+func (d *dsKv) EnumeratePage(prefix string, pageSize int, token string) ([]string, string, error) {
+	if err := d.check(d.ctx); err != nil {
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	after, err := parsePageToken(token)
+	if err != nil {
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	tx := d.newTx(d.ctx)
+	n, err := tx.tree(prefix)
+	if err != nil {
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	p := &pageCollector{prefix: prefix, after: after, pageSize: pageSize}
+	if err := scanRange(tx.ctx, newMemCursor(n, tx.now), "", splitPath(after), nil, false, p.add); err != nil && err != errStop {
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	keys, next := p.page()
+	return keys, next, nil
+}
+
+// Scan lists pairs under prefix within a range in the order defined by
+// Key.Less, loading the bucket from a single ancestor query.
+func (d *dsKv) Scan(prefix, start, end string, reverse bool, limit int) ([]Pair, error) {
+	s := &scanCollector{prefix: prefix, limit: limit, values: true}
+	if err := d.scan(prefix, start, end, reverse, s); err != nil {
+		return nil, err
+	}
+
+	return s.pairs, nil
+}
+
+// ScanKeys lists keys under prefix within a range.
+func (d *dsKv) ScanKeys(prefix, start, end string, reverse bool, limit int) ([]string, error) {
+	s := &scanCollector{prefix: prefix, limit: limit}
+	if err := d.scan(prefix, start, end, reverse, s); err != nil {
+		return nil, err
+	}
+
+	return s.keys(), nil
+}
+
+func (d *dsKv) scan(prefix, start, end string, reverse bool, s *scanCollector) error {
+	if err := d.check(d.ctx); err != nil {
+		return keyError("scan", prefix, err)
+	}
+
+	startKeys, endKeys, err := scanBounds(start, end)
+	if err != nil {
+		return keyError("scan", prefix, err)
+	}
+
+	tx := d.newTx(d.ctx)
+	n, err := tx.tree(prefix)
+	if err != nil {
+		return keyError("scan", prefix, err)
+	}
+
+	if err := scanRange(tx.ctx, newMemCursor(n, tx.now), "", startKeys, endKeys, reverse, s.add); err != nil && err != errStop {
+		return keyError("scan", prefix, err)
+	}
+
+	return nil
+}
+
+// getMulti fetches entities for dsKeys in a single round trip, reporting
+// missing entities as nil buffers.
+func (tx *dsTx) getMulti(dsKeys []*datastore.Key) ([]*Buffer, error) {
+	bufs := make([]*Buffer, len(dsKeys))
+	for i := range bufs {
+		bufs[i] = new(Buffer)
+	}
+
+	var err error
+	if tx.tx != nil {
+		err = tx.tx.GetMulti(dsKeys, bufs)
+	} else {
+		err = tx.d.client.GetMulti(tx.ctx, dsKeys, bufs)
+	}
+
+	if err != nil {
+		me, ok := err.(datastore.MultiError)
+		if !ok {
+			return nil, err
+		}
+
+		for i, err := range me {
+			switch err {
+			case nil:
+			case datastore.ErrNoSuchEntity:
+				bufs[i] = nil
+			default:
+				return nil, err
+			}
+		}
+	}
+
+	for i, k := range dsKeys {
+		if b, ok := tx.pending[k.Name]; ok {
+			bufs[i] = b
+		} else if tx.isExpired(bufs[i]) {
+			tx.expired = append(tx.expired, k)
+		}
+
+		if tx.isExpired(bufs[i]) {
+			bufs[i] = nil
+		}
+	}
+
+	return bufs, nil
+}
+
+// isExpired reports whether b is a leaf that has expired.
+func (tx *dsTx) isExpired(b *Buffer) bool {
+	return b != nil && b.Valid && expired(b.Expires, tx.now)
+}
+
+// putMulti writes entities, in batches when not in a transaction.
+func (tx *dsTx) putMulti(dsKeys []*datastore.Key, bufs []*Buffer) error {
+	if tx.tx != nil {
+		if _, err := tx.tx.PutMulti(dsKeys, bufs); err != nil {
+			return err
+		}
+
+		for i, k := range dsKeys {
+			tx.pending[k.Name] = bufs[i]
+		}
+
+		return nil
+	}
+
+	for len(dsKeys) > 0 {
+		n := len(dsKeys)
+		if n > maxBatch {
+			n = maxBatch
+		}
+
+		if _, err := tx.d.client.PutMulti(tx.ctx, dsKeys[:n], bufs[:n]); err != nil {
+			return err
+		}
+
+		dsKeys, bufs = dsKeys[n:], bufs[n:]
+	}
+
+	return nil
+}
+
+// deleteMulti deletes entities, in batches when not in a transaction.
+func (tx *dsTx) deleteMulti(dsKeys []*datastore.Key) error {
+	if tx.tx != nil {
+		if err := tx.tx.DeleteMulti(dsKeys); err != nil {
+			return err
+		}
+
+		for _, k := range dsKeys {
+			tx.pending[k.Name] = nil
+		}
+
+		return nil
+	}
+
+	for len(dsKeys) > 0 {
+		n := len(dsKeys)
+		if n > maxBatch {
+			n = maxBatch
+		}
+
+		if err := tx.d.client.DeleteMulti(tx.ctx, dsKeys[:n]); err != nil {
+			return err
+		}
+
+		dsKeys = dsKeys[n:]
+	}
+
+	return nil
+}
+
+// checkPath ensures that every buffer along a path exists and is a bucket.
+func checkPath(bufs []*Buffer) error {
+	for _, b := range bufs {
+		if b == nil {
+			return ErrNotFound
+		}
+
+		if b.Valid {
+			return ErrPathIsValue
+		}
+	}
+
+	return nil
+}
+
+func (tx *dsTx) Get(key string) ([]byte, error) {
+	if err := tx.ctx.Err(); err != nil {
+		return nil, keyError("get", key, err)
+	}
+
+	b, err := tx.leaf(key)
+	if err != nil {
+		return nil, keyError("get", key, err)
+	}
+
+	val := make([]byte, len(b.Value))
+	copy(val, b.Value)
+
+	return val, nil
+}
+
+// SetWithTTL sets a value that expires after ttl.
+func (tx *dsTx) SetWithTTL(key string, val []byte, ttl time.Duration) error {
+	if err := checkTTL(ttl); err != nil {
+		return keyError("set", key, err)
+	}
+
+	return tx.set(key, val, tx.now.Add(ttl))
+}
+
+// TTL returns the time left before a key expires.
+func (tx *dsTx) TTL(key string) (time.Duration, error) {
+	b, err := tx.leaf(key)
+	if err != nil {
+		return 0, keyError("ttl", key, err)
+	}
+
+	return timeLeft(b.Expires, tx.now), nil
+}
+
+// leaf returns the buffer of the leaf key points to.
+func (tx *dsTx) leaf(key string) (*Buffer, error) {
+	keys, err := splitKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, ErrEmptyKey
+	}
+
+	bufs, err := tx.getMulti(tx.d.dsKeys(keys))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPath(bufs[:len(bufs)-1]); err != nil {
+		return nil, err
+	}
+
+	b := bufs[len(bufs)-1]
+	if b == nil {
+		return nil, ErrNotFound
+	}
+
+	if !b.Valid {
+		return nil, ErrIsBucket
+	}
+
+	return b, nil
+}
+
+// expiry returns the time the leaf at key expires at, or zero if it does not expire.
+func (tx *dsTx) expiry(key string) time.Time {
+	b, err := tx.leaf(key)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return b.Expires
+}
+
+// getRevision gets a value along with the version of its revision.
+func (tx *dsTx) getRevision(key string) ([]byte, Version, error) {
+	val, err := tx.Get(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	b, err := tx.leaf(key)
+	if err != nil {
+		return nil, "", keyError("get", key, err)
+	}
+
+	if b.Revision == "" {
+		return val, versionOf(val), nil
+	}
+
+	return val, Version(b.Revision), nil
+}
+
+func (tx *dsTx) Set(key string, val []byte) error {
+	return tx.set(key, val, time.Time{})
+}
+
+// set sets a value that expires at expires, or never if expires is zero.
+// Expired leaves along the path are overwritten by buckets.
+func (tx *dsTx) set(key string, val []byte, expires time.Time) error {
+	if err := tx.ctx.Err(); err != nil {
+		return keyError("set", key, err)
+	}
+
+	if !tx.writable {
+		return keyError("set", key, ErrReadOnly)
+	}
+
+	keys, err := splitKey(key)
+	if err != nil {
+		return keyError("set", key, err)
+	}
+
+	if len(keys) == 0 {
+		return keyError("set", key, ErrEmptyKey)
+	}
+
+	if val == nil {
+		return keyError("set", key, ErrNilValue)
+	}
+
+	dsKeys := tx.d.dsKeys(keys)
+	bufs, err := tx.getMulti(dsKeys)
+	if err != nil {
+		return keyError("set", key, err)
+	}
+
+	// put missing buckets along the path followed by the value itself.
+	var putKeys []*datastore.Key
+	var putBufs []*Buffer
+	for i, b := range bufs[:len(bufs)-1] {
+		if b == nil {
+			putKeys = append(putKeys, dsKeys[i])
+			putBufs = append(putBufs, new(Buffer))
+		} else if b.Valid {
+			return keyError("set", key, ErrPathIsValue)
+		}
+	}
+
+	if b := bufs[len(bufs)-1]; b != nil && !b.Valid {
+		return keyError("set", key, ErrIsBucket)
+	}
+
+	rev, err := newRevision()
+	if err != nil {
+		return keyError("set", key, err)
+	}
+
+	b := &Buffer{Valid: true, Value: make([]byte, len(val)), Expires: expires, Revision: rev}
+	copy(b.Value, val)
+	putKeys = append(putKeys, dsKeys[len(dsKeys)-1])
+	putBufs = append(putBufs, b)
+
+	return keyError("set", key, tx.putMulti(putKeys, putBufs))
+}
+
+// createBucket creates a bucket at key along with buckets along the path,
+// leaving an existing bucket as it is.
+func (tx *dsTx) createBucket(key string) error {
+	if err := tx.ctx.Err(); err != nil {
+		return keyError("create", key, err)
+	}
+
+	if !tx.writable {
+		return keyError("create", key, ErrReadOnly)
+	}
+
+	keys, err := splitKey(key)
+	if err != nil {
+		return keyError("create", key, err)
+	}
+
+	if len(keys) == 0 {
+		return keyError("create", key, ErrEmptyKey)
+	}
+
+	dsKeys := tx.d.dsKeys(keys)
+	bufs, err := tx.getMulti(dsKeys)
+	if err != nil {
+		return keyError("create", key, err)
+	}
+
+	var putKeys []*datastore.Key
+	var putBufs []*Buffer
+	for i, b := range bufs {
+		if b == nil {
+			putKeys = append(putKeys, dsKeys[i])
+			putBufs = append(putBufs, new(Buffer))
+		} else if b.Valid {
+			return keyError("create", key, ErrPathIsValue)
+		}
+	}
+
+	if len(putKeys) == 0 {
+		return nil
+	}
+
+	return keyError("create", key, tx.putMulti(putKeys, putBufs))
+}
+
+func (tx *dsTx) Delete(key string) error {
+	if err := tx.ctx.Err(); err != nil {
+		return keyError("delete", key, err)
+	}
+
+	if !tx.writable {
+		return keyError("delete", key, ErrReadOnly)
+	}
+
+	keys, err := splitKey(key)
+	if err != nil {
+		return keyError("delete", key, err)
+	}
+
+	if len(keys) == 0 {
+		return keyError("delete", key, ErrEmptyKey)
+	}
+
+	dsKeys, _, err := tx.enumerate(key, true, true)
+	if err != nil {
+		return keyError("delete", key, err)
+	}
+
+	return keyError("delete", key, tx.deleteMulti(dsKeys))
+}
+
+func (tx *dsTx) Enumerate(key string) ([]string, error) {
+	pairs, err := tx.EnumerateValues(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var outKeys []string
+	for _, p := range pairs {
+		outKeys = append(outKeys, p.Key)
+	}
+
+	return outKeys, nil
+}
+
+// EnumerateValues lists leaves under a key along with their values. Values
+// come from the same ancestor query that lists the keys.
+func (tx *dsTx) EnumerateValues(key string) ([]Pair, error) {
+	if err := tx.ctx.Err(); err != nil {
+		return nil, keyError("enumerate", key, err)
+	}
+
+	dsKeys, bufs, err := tx.enumerate(key, false, false)
+	if err != nil {
+		return nil, keyError("enumerate", key, err)
+	}
+
+	// entity names hold the full path, so strip the path of key from them
+	// and join what remains onto key as given by the caller.
+	prefix := splitPath(key).path()
+	var pairs []Pair
+	for i, k := range dsKeys {
+		if bufs[i].Valid {
+			pairs = append(pairs, Pair{
+				Key:   joinKey(key, relativeName(k.Name, prefix)),
+				Value: bufs[i].Value,
+			})
+		}
+	}
+
+	// query results come in key order, but pending writes are appended.
+	sort.Slice(pairs, func(i, j int) bool { return lessKey(pairs[i].Key, pairs[j].Key) })
+
+	return pairs, nil
+}
+
+// Stat describes a key. Children of a bucket are counted with an ancestor
+// query.
+func (tx *dsTx) Stat(key string) (Info, error) {
+	if err := tx.ctx.Err(); err != nil {
+		return Info{}, keyError("stat", key, err)
+	}
+
+	var info Info
+	err := func() error {
+		keys, err := splitKey(key)
+		if err != nil {
+			return err
+		}
+
+		if len(keys) == 0 {
+			return ErrEmptyKey
+		}
+
+		bufs, err := tx.getMulti(tx.d.dsKeys(keys))
+		if err != nil {
+			return err
+		}
+
+		if err := checkPath(bufs[:len(bufs)-1]); err != nil {
+			return err
+		}
+
+		b := bufs[len(bufs)-1]
+		if b == nil {
+			return ErrNotFound
+		}
+
+		if b.Valid {
+			info.Size = len(b.Value)
+			return nil
+		}
+
+		dsKeys, _, err := tx.enumerate(key, false, false)
+		if err != nil {
+			return err
+		}
+
+		base := Key(keys).path()
+		info.IsBucket = true
+		for _, k := range dsKeys {
+			if k.Name != base && len(splitPath(relativeName(k.Name, base))) == 1 {
+				info.Children++
+			}
+		}
+
+		return nil
+	}()
+
+	return statResult(key, info, err)
+}
+
+// List lists the immediate children of a bucket. Datastore cannot restrict an
+// ancestor query to direct children, so all entities under prefix are fetched
+// and children of children are only counted.
+func (tx *dsTx) List(prefix string) ([]Entry, error) {
+	if err := tx.ctx.Err(); err != nil {
+		return nil, keyError("list", prefix, err)
+	}
+
+	dsKeys, bufs, err := tx.enumerate(prefix, false, false)
+	if err != nil {
+		return nil, keyError("list", prefix, err)
+	}
+
+	base := splitPath(prefix).path()
+	var entries []Entry
+	children := make(map[string]int)
+	for i, k := range dsKeys {
+		if k.Name == base {
+			continue
+		}
+
+		segments := splitPath(relativeName(k.Name, base))
+		switch len(segments) {
+		case 1:
+			entries = append(entries, Entry{
+				Key:      joinKey(prefix, escapeSegment(segments[0])),
+				Name:     segments[0],
+				IsBucket: !bufs[i].Valid,
+			})
+		case 2:
+			children[segments[0]]++
+		}
+	}
+
+	for i := range entries {
+		entries[i].Children = children[entries[i].Name]
+	}
+
+	// query results come in key order, but pending writes are appended.
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+	return entries, nil
+}
+
+// enumerate lists entities under key including the entity for key itself.
+// If leaf is false, key must point to a bucket. Buffers are only fetched
+// if keysOnly is false.
+func (tx *dsTx) enumerate(key string, leaf, keysOnly bool) ([]*datastore.Key, []*Buffer, error) {
+	keys, err := splitKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ancestor *datastore.Key
+	if len(keys) > 0 {
+		dsKeys := tx.d.dsKeys(keys)
+		bufs, err := tx.getMulti(dsKeys)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err := checkPath(bufs[:len(bufs)-1]); err != nil {
+			return nil, nil, err
+		}
+
+		b := bufs[len(bufs)-1]
+		if b == nil {
+			return nil, nil, ErrNotFound
+		}
+
+		if b.Valid {
+			if !leaf {
+				return nil, nil, ErrPathIsValue
+			}
+			return dsKeys[len(dsKeys)-1:], bufs[len(bufs)-1:], nil
+		}
+
+		ancestor = dsKeys[len(dsKeys)-1]
+	}
+
+	q := tx.query(ancestor)
+	if keysOnly {
+		q = q.KeysOnly()
+	}
+
+	var bufs []*Buffer
+	var dst interface{}
+	if !keysOnly {
+		dst = &bufs
+	}
+
+	dsKeys, err := tx.d.client.GetAll(tx.ctx, q, dst)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if keysOnly {
+		bufs = make([]*Buffer, len(dsKeys))
+	}
+
+	if !keysOnly {
+		// drop expired leaves, which are only known once buffers are fetched.
+		var outKeys []*datastore.Key
+		var outBufs []*Buffer
+		for i, k := range dsKeys {
+			if !tx.isExpired(bufs[i]) {
+				outKeys = append(outKeys, k)
+				outBufs = append(outBufs, bufs[i])
+			}
+		}
+		dsKeys, bufs = outKeys, outBufs
+	}
+
+	if len(tx.pending) == 0 {
+		return dsKeys, bufs, nil
+	}
+
+	// overlay writes pending in this transaction onto the query results.
+	var outKeys []*datastore.Key
+	var outBufs []*Buffer
+	seen := make(map[string]bool)
+	for i, k := range dsKeys {
+		seen[k.Name] = true
+		b := bufs[i]
+		if p, ok := tx.pending[k.Name]; ok {
+			if p == nil {
+				continue
+			}
+			b = p
+		}
+		outKeys = append(outKeys, k)
+		outBufs = append(outBufs, b)
+	}
+
+	for name, b := range tx.pending {
+		if b == nil || seen[name] {
+			continue
+		}
+
+		if ancestor == nil || name == ancestor.Name || strings.HasPrefix(name, ancestor.Name+"/") {
+			outKeys = append(outKeys, tx.d.dsKey(name))
+			outBufs = append(outBufs, b)
+		}
+	}
+
+	return outKeys, outBufs, nil
+}
+
+// walk calls f for each leaf under the bucket key points to in the order
+// defined by Key.Less. Pending writes are not overlaid, so walk is meant for
+// read-only use.
+func (tx *dsTx) walk(key string, f func(key string, val []byte) error) error {
+	n, err := tx.tree(key)
+	if err != nil {
+		return err
+	}
+
+	return scanRange(tx.ctx, newMemCursor(n, tx.now), "", nil, nil, false, func(rel string, val []byte) error {
+		return f(joinKey(key, rel), val)
+	})
+}
+
+// tree loads leaves under the bucket key points to from a single ancestor
+// query into a tree of nodes, so that they can be walked with a memCursor in
+// the order defined by Key.Less like on other backends. Datastore orders
+// entities by their escaped names, which sort segments holding escaped
+// characters differently. Expired leaves are left out.
+func (tx *dsTx) tree(key string) (*node, error) {
+	ancestor, err := tx.bucketKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &node{links: make(map[string]*node)}
+	prefix := splitPath(key).path()
+	it := tx.d.client.Run(tx.ctx, tx.query(ancestor).Filter("Valid =", true))
+	for {
+		b := new(Buffer)
+		k, err := it.Next(b)
+		if err == iterator.Done {
+			return root, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if tx.isExpired(b) {
+			continue
+		}
+
+		n := root
+		for _, seg := range splitPath(relativeName(k.Name, prefix)) {
+			l, ok := n.links[seg]
+			if !ok {
+				l = &node{links: make(map[string]*node)}
+				n.links[seg] = l
+			}
+			n = l
+		}
+
+		n.value = b.Value
+		if n.value == nil {
+			n.value = []byte{}
+		}
+	}
+}
+
+// bucketKey returns the datastore key of the bucket key points to,
+// which is nil for the namespace root.
+func (tx *dsTx) bucketKey(key string) (*datastore.Key, error) {
+	keys, err := splitKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	dsKeys := tx.d.dsKeys(keys)
+	bufs, err := tx.getMulti(dsKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPath(bufs); err != nil {
+		return nil, err
+	}
+
+	return dsKeys[len(dsKeys)-1], nil
+}
+
+// query returns a query for every entity under the bucket with datastore key
+// ancestor, bound to the transaction if there is one. Queries without an
+// ancestor, as needed for the namespace root, cannot run within a transaction.
+func (tx *dsTx) query(ancestor *datastore.Key) *datastore.Query {
+	q := datastore.NewQuery(tx.d.nameSpace)
+	if ancestor == nil {
+		return q
+	}
+
+	q = q.Ancestor(ancestor)
+	if tx.tx != nil {
+		q = q.Transaction(tx.tx)
+	}
+
+	return q
+}
+
+// relativeName returns name relative to the bucket path prefix.
+func relativeName(name, prefix string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return strings.TrimPrefix(name, prefix+"/")
 }
@@ -1,396 +1,59 @@
-package kv
+package kv_test
 
 import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
 	"testing"
-)
-
-func TestDataStoreKv_GetSet(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, []byte(val)); err != nil {
-		t.Fatal(err)
-	}
-
-	// get that thing
-	if retVal, err := kv.Get(key); err != nil {
-		t.Fatal(err)
-	} else if string(retVal) != val {
-		t.Fatal("not val")
-	}
-}
-
-func TestDataStoreKv_GetSet2(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, []byte(val)); err != nil {
-		t.Fatal(err)
-	}
-
-	// get that thing
-	if retVal, err := kv.Get(key); err != nil {
-		t.Fatal(err)
-	} else if string(retVal) != val {
-		t.Fatal("not val")
-	}
-}
-
-func TestDataStoreKv_GetSetWrongKey(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, []byte(val)); err != nil {
-		t.Fatal(err)
-	}
-
-	// get that thing
-	if val, err := kv.Get("wrongKey"); err == nil {
-		t.Fatal("expected error here, got value length:", len(val))
-	}
-}
-
-func TestDataStoreKv_GetSetWrongBucket(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, []byte(val)); err != nil {
-		t.Fatal(err)
-	}
-
-	// get that thing
-	if val, err := kv.Get("/a/b/d/this"); err == nil {
-		t.Fatal("expected error here, got value length:", len(val))
-	}
-}
-
-func TestDataStoreKv_SetEmptyKey(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set("", []byte(val)); err == nil {
-		t.Fatal("expected err here")
-	}
-}
-
-func TestDataStoreKv_GetEmptyKey(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, []byte(val)); err != nil {
-		t.Fatal(err)
-	}
-
-	// get that thing
-	if val, err := kv.Get(""); err == nil {
-		t.Fatal("expected error here, got value length:", len(val))
-	}
-}
+	"time"
 
-func TestDataStoreKv_GetBucket(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, []byte(val)); err != nil {
-		t.Fatal(err)
-	}
-
-	// get that thing
-	if val, err := kv.Get("/a/b/c"); err == nil {
-		t.Fatal("expected error here, got value length:", len(val))
-	}
-}
-
-func TestDataStoreKv_SetNilValue(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, nil); err == nil {
-		t.Fatal("expected err when trying to set a nil value")
-	}
-}
-
-func TestDataStoreKv_SetZeroValue(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, []byte{}); err != nil {
-		t.Fatal(err)
-	}
-}
-
-func TestDataStoreKv_GetZeroValue(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
+	"github.com/sdeoras/kv"
+	"github.com/sdeoras/kv/kvtest"
+)
 
-	// set something
-	if err := kv.Set(key, []byte{}); err != nil {
-		t.Fatal(err)
+func TestDataStoreKv(t *testing.T) {
+	projectID := os.Getenv("GOOGLE_PROJECT")
+	if projectID == "" {
+		t.Skip("GOOGLE_PROJECT not set")
 	}
 
-	if val, err := kv.Get(key); err != nil {
-		t.Fatal(err)
-	} else {
-		if val == nil {
-			t.Fatal("expected val to be zero length but not nil, got nil")
-		} else {
-			if len(val) != 0 {
-				t.Fatal("expected val to be zero length, got:", len(val))
-			}
+	kvtest.RunConformanceWithClock(t, func(now func() time.Time) (kv.KV, func()) {
+		// each test gets its own namespace so that tests do not see each other's keys.
+		ns := fmt.Sprintf("kvtest-%d", time.Now().UnixNano())
+		db, closeKv, err := kv.NewDataStoreKv(context.Background(), projectID, ns,
+			kv.WithClock(now), kv.WithPollInterval(100*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
 		}
-	}
-}
 
-func TestDataStoreKv_DeleteKey(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, []byte(val)); err != nil {
-		t.Fatal(err)
-	}
-
-	// now delete the tree
-	if err := kv.Delete(key); err != nil {
-		t.Fatal(err)
-	}
-
-	// now ensure you can't get that thing
-	if val, err := kv.Get(key); err == nil && val != nil {
-		t.Fatal("expected returned value to be nil, got slice length:", len(val))
-	}
-}
-
-func TestDataStoreKv_DeleteTree(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, []byte(val)); err != nil {
-		t.Fatal(err)
-	}
-
-	bktName, _ := filepath.Split(key)
-	// set something else in the same bucket
-	if err := kv.Set(filepath.Join(bktName, "someOtherKey"), []byte("someOtherValue")); err != nil {
-		t.Fatal(err)
-	}
-
-	// now delete the tree
-	if err := kv.Delete("/a/b"); err != nil {
-		t.Fatal(err)
-	}
-
-	// now ensure you can't get that thing
-	if val, err := kv.Get(key); err == nil && val != nil {
-		t.Fatal("expected returned value to be nil, got slice length:", len(val))
-	}
-
-	// now ensure you can't get that other thing as well
-	if val, err := kv.Get(filepath.Join(bktName, "someOtherKey")); err == nil && val != nil {
-		t.Fatal("expected returned value to be nil, got slice length:", len(val))
-	}
-}
-
-func TestDataStoreKv_DeleteDeletedKey(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, []byte(val)); err != nil {
-		t.Fatal(err)
-	}
-
-	bktName, _ := filepath.Split(key)
-	// set something else in the same bucket
-	if err := kv.Set(filepath.Join(bktName, "someOtherKey"), []byte("someOtherValue")); err != nil {
-		t.Fatal(err)
-	}
-
-	// now delete the key
-	if err := kv.Delete(key); err != nil {
-		t.Fatal(err)
-	}
-
-	// now delete the key
-	if err := kv.Delete(key); err == nil {
-		t.Fatal("expected error when deleting key twice")
-	}
-}
-
-func TestDataStoreKv_Enumerate(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, []byte(val)); err != nil {
-		t.Fatal(err)
-	}
-
-	bktName, _ := filepath.Split(key)
-	// set something else in the same bucket
-	if err := kv.Set(filepath.Join(bktName, "someOtherKey"), []byte("someOtherValue")); err != nil {
-		t.Fatal(err)
-	}
-
-	keys, err := kv.Enumerate("/a/b")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	for _, key := range keys {
-		switch _, v := filepath.Split(key); v {
-		case "myKey", "someOtherKey":
-		default:
-			t.Fatal("did not expect this key to be present in the list:", key)
+		return db, func() {
+			keys, _ := db.Enumerate("/")
+			done := make(map[string]bool)
+			for _, key := range keys {
+				top := strings.Split(strings.TrimPrefix(key, "/"), "/")[0]
+				if !done[top] {
+					_ = db.Delete(top)
+					done[top] = true
+				}
+			}
+			_ = closeKv()
 		}
-	}
+	})
 }
 
-func TestDataStoreKv_DeleteEnumerate(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, []byte(val)); err != nil {
-		t.Fatal(err)
-	}
-
-	bktName, _ := filepath.Split(key)
-	// set something else in the same bucket
-	if err := kv.Set(filepath.Join(bktName, "someOtherKey"), []byte("someOtherValue")); err != nil {
-		t.Fatal(err)
-	}
-
-	if err := kv.Delete(key); err != nil {
-		t.Fatal(err)
-	}
-
-	keys, err := kv.Enumerate("/a/b/")
-	if err != nil {
-		t.Fatal(err)
+func TestDataStoreStore(t *testing.T) {
+	projectID := os.Getenv("GOOGLE_PROJECT")
+	if projectID == "" {
+		t.Skip("GOOGLE_PROJECT not set")
 	}
 
-	if len(keys) != 1 {
-		t.Fatal("expected only one key, found:", len(keys))
-	}
-
-	for _, key := range keys {
-		switch _, v := filepath.Split(key); v {
-		case "someOtherKey":
-		default:
-			t.Fatal("did not expect this key to be present in the list:", key)
+	kvtest.RunStoreConformance(t, func() (kv.Store, func()) {
+		s, closeStore, err := kv.NewDataStoreStore(context.Background(), projectID)
+		if err != nil {
+			t.Fatal(err)
 		}
-	}
-}
-
-func TestDataStoreKv_DeleteAllEnumerate(t *testing.T) {
-	kv, closeKv, err := NewDataStoreKv(context.Background(), os.Getenv("GOOGLE_PROJECT"), "test")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	defer closeKv()
-
-	// set something
-	if err := kv.Set(key, []byte(val)); err != nil {
-		t.Fatal(err)
-	}
 
-	bktName, _ := filepath.Split(key)
-	// set something else in the same bucket
-	if err := kv.Set(filepath.Join(bktName, "someOtherKey"), []byte("someOtherValue")); err != nil {
-		t.Fatal(err)
-	}
-
-	if err := kv.Delete(key); err != nil {
-		t.Fatal(err)
-	}
-
-	if err := kv.Delete(filepath.Join(bktName, "someOtherKey")); err != nil {
-		t.Fatal(err)
-	}
-
-	keys, err := kv.Enumerate("/a/b/")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if len(keys) > 0 {
-		fmt.Println(keys)
-		t.Fatal("did not expect any key to be listed, found:", len(keys))
-	}
+		return s, func() { _ = closeStore() }
+	})
 }
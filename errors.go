@@ -0,0 +1,96 @@
+package kv
+
+import "errors"
+
+var (
+	// ErrNotFound is returned when a key or bucket does not exist.
+	ErrNotFound = errors.New("key not found")
+	// ErrIsBucket is returned when a key expected to hold a value points to a bucket.
+	ErrIsBucket = errors.New("key points to a bucket, not a value")
+	// ErrPathIsValue is returned when a path expected to name buckets
+	// runs into a value, e.g. setting a/b/c when a/b holds a value.
+	ErrPathIsValue = errors.New("path crosses a value, not a bucket")
+	// ErrEmptyKey is returned when an empty key is supplied.
+	ErrEmptyKey = errors.New("key cannot be empty")
+	// ErrInvalidKey is returned when a key cannot be parsed, e.g. because
+	// it has empty segments or segments that are . or ..
+	ErrInvalidKey = errors.New("invalid key")
+	// ErrInvalidNamespace is returned when a namespace name is empty, starts
+	// with __, or contains control characters.
+	ErrInvalidNamespace = errors.New("invalid namespace")
+	// ErrNilValue is returned when a nil value is set. Use a zero length value instead.
+	ErrNilValue = errors.New("value cannot be nil, use zero value instead")
+	// ErrClosed is returned when the database has been closed.
+	ErrClosed = errors.New("database is closed")
+	// ErrReadOnly is returned when writing within a read-only transaction.
+	ErrReadOnly = errors.New("transaction is read-only")
+	// ErrInvalidArgument is returned when an argument other than a key is
+	// out of range, e.g. a page size that is not positive.
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrInvalidToken is returned when a page token cannot be parsed.
+	ErrInvalidToken = errors.New("invalid page token")
+	// ErrExists is returned when creating a key that already exists.
+	ErrExists = errors.New("key already exists")
+	// ErrConflict is returned when a conditional write finds the key
+	// in a state other than the one it was conditioned on.
+	ErrConflict = errors.New("key was modified concurrently")
+)
+
+// KeyError records an error and the operation and key that caused it.
+// Use errors.Is to check for the sentinel errors defined in this package.
+type KeyError struct {
+	Op  string
+	Key string
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	return e.Op + " " + e.Key + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// keyError wraps err in a KeyError unless it is nil or already one.
+func keyError(op, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := err.(*KeyError); ok {
+		return err
+	}
+
+	return &KeyError{Op: op, Key: key, Err: err}
+}
+
+// NamespaceError records an error and the operation and namespace that caused it.
+type NamespaceError struct {
+	Op        string
+	Namespace string
+	Err       error
+}
+
+func (e *NamespaceError) Error() string {
+	return e.Op + " namespace " + e.Namespace + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *NamespaceError) Unwrap() error {
+	return e.Err
+}
+
+// namespaceError wraps err in a NamespaceError unless it is nil or already one.
+func namespaceError(op, ns string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := err.(*NamespaceError); ok {
+		return err
+	}
+
+	return &NamespaceError{Op: op, Namespace: ns, Err: err}
+}
@@ -0,0 +1,13 @@
+package kv
+
+import "time"
+
+// WithTicks makes janitors reclaim expired keys whenever a time is received
+// from ticks rather than every interval.
+func WithTicks(ticks <-chan time.Time) Option {
+	return func(o *options) {
+		o.ticker = func(time.Duration) (<-chan time.Time, func()) {
+			return ticks, func() {}
+		}
+	}
+}
@@ -1,18 +1,18 @@
 module github.com/sdeoras/kv
 
-go 1.12
+go 1.13
 
 require (
 	cloud.google.com/go v0.37.4
 	github.com/boltdb/bolt v1.3.1
-	github.com/golang/protobuf v1.3.1 // indirect
+	github.com/golang/protobuf v1.3.1
 	github.com/hashicorp/golang-lru v0.5.1 // indirect
 	go.opencensus.io v0.20.2 // indirect
 	golang.org/x/net v0.0.0-20190424112056-4829fb13d2c6 // indirect
 	golang.org/x/oauth2 v0.0.0-20190402181905-9f3314589c9a // indirect
 	golang.org/x/sys v0.0.0-20190422165155-953cdadca894 // indirect
-	google.golang.org/api v0.3.2 // indirect
+	google.golang.org/api v0.3.2
 	google.golang.org/appengine v1.5.0 // indirect
 	google.golang.org/genproto v0.0.0-20190418145605-e7d98fc518a7 // indirect
-	google.golang.org/grpc v1.20.1 // indirect
+	google.golang.org/grpc v1.20.1
 )
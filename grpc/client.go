@@ -0,0 +1,171 @@
+package grpc
+
+import (
+	"context"
+	"io"
+
+	"github.com/sdeoras/kv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var (
+	_ kv.KVContext = (*grpcKv)(nil)
+	_ kv.Watcher   = (*grpcKv)(nil)
+)
+
+// grpcKv implements kv.KV against a server serving NewServer.
+type grpcKv struct {
+	client KVClient
+}
+
+// NewGRPCKv provides a new instance of kv.KV backed by a server at target
+// serving NewServer. opts are passed on to grpc.Dial and must include
+// transport credentials, or grpc.WithInsecure().
+func NewGRPCKv(target string, opts ...grpc.DialOption) (kv.KV, kv.CloseFunc, error) {
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &grpcKv{client: NewKVClient(conn)}, conn.Close, nil
+}
+
+// callError wraps an error returned by a call in a *kv.KeyError, rebuilding
+// sentinel errors from trailer.
+func callError(ctx context.Context, op, key string, err error, trailer metadata.MD) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	} else {
+		err = fromStatus(err, trailer)
+	}
+
+	return &kv.KeyError{Op: op, Key: key, Err: err}
+}
+
+func (g *grpcKv) Set(key string, val []byte) error {
+	return g.SetContext(context.Background(), key, val)
+}
+
+func (g *grpcKv) SetContext(ctx context.Context, key string, val []byte) error {
+	// empty values arrive as nil, so nil values are rejected here.
+	if val == nil {
+		return &kv.KeyError{Op: "set", Key: key, Err: kv.ErrNilValue}
+	}
+
+	var trailer metadata.MD
+	if _, err := g.client.Set(ctx, &SetRequest{Key: key, Value: val}, grpc.Trailer(&trailer)); err != nil {
+		return callError(ctx, "set", key, err, trailer)
+	}
+
+	return nil
+}
+
+func (g *grpcKv) Get(key string) ([]byte, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+func (g *grpcKv) GetContext(ctx context.Context, key string) ([]byte, error) {
+	var trailer metadata.MD
+	resp, err := g.client.Get(ctx, &GetRequest{Key: key}, grpc.Trailer(&trailer))
+	if err != nil {
+		return nil, callError(ctx, "get", key, err, trailer)
+	}
+
+	if resp.Value == nil {
+		return []byte{}, nil
+	}
+
+	return resp.Value, nil
+}
+
+func (g *grpcKv) Delete(key string) error {
+	return g.DeleteContext(context.Background(), key)
+}
+
+func (g *grpcKv) DeleteContext(ctx context.Context, key string) error {
+	var trailer metadata.MD
+	if _, err := g.client.Delete(ctx, &DeleteRequest{Key: key}, grpc.Trailer(&trailer)); err != nil {
+		return callError(ctx, "delete", key, err, trailer)
+	}
+
+	return nil
+}
+
+func (g *grpcKv) Enumerate(key string) ([]string, error) {
+	return g.EnumerateContext(context.Background(), key)
+}
+
+func (g *grpcKv) EnumerateContext(ctx context.Context, key string) ([]string, error) {
+	stream, err := g.client.Enumerate(ctx, &EnumerateRequest{Key: key})
+	if err != nil {
+		return nil, callError(ctx, "enumerate", key, err, nil)
+	}
+
+	var keys []string
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, callError(ctx, "enumerate", key, err, stream.Trailer())
+		}
+
+		keys = append(keys, resp.Key)
+	}
+}
+
+// Watch reports changes streamed by the server until ctx is done. It returns
+// once the server has set up the watch, so that no later change is missed.
+func (g *grpcKv) Watch(ctx context.Context, prefix string) (<-chan kv.Event, error) {
+	stream, err := g.client.Watch(ctx, &WatchRequest{Prefix: prefix})
+	if err != nil {
+		return nil, callError(ctx, "watch", prefix, err, nil)
+	}
+
+	md, err := stream.Header()
+	if err != nil {
+		return nil, callError(ctx, "watch", prefix, err, stream.Trailer())
+	}
+
+	if len(md.Get(watchHeader)) == 0 {
+		// the watch failed, so the stream ends with its status.
+		_, err := stream.Recv()
+		return nil, callError(ctx, "watch", prefix, err, stream.Trailer())
+	}
+
+	out := make(chan kv.Event)
+	go func() {
+		defer close(out)
+
+		for {
+			e, err := stream.Recv()
+			if err != nil {
+				return
+			}
+
+			var event kv.Event
+			switch e.Kind {
+			case Event_PUT:
+				event = kv.Event{Kind: kv.EventPut, Key: e.Key, Value: e.Value}
+				if event.Value == nil {
+					event.Value = []byte{}
+				}
+			case Event_DELETE:
+				event = kv.Event{Kind: kv.EventDelete, Key: e.Key}
+			default:
+				// skip kinds this client does not know of.
+				continue
+			}
+
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
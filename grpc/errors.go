@@ -0,0 +1,90 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sdeoras/kv"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// errorTrailer carries the name of a sentinel error in trailers.
+	errorTrailer = "kv-error"
+	// watchHeader is sent in headers once a watch is set up, telling it apart
+	// from a watch that failed, whose status arrives without other headers.
+	watchHeader = "kv-watching"
+)
+
+// sentinels maps sentinel errors of package kv to names sent in errorTrailer
+// along with the status code they are served with.
+var sentinels = []struct {
+	err  error
+	name string
+	code codes.Code
+}{
+	{kv.ErrNotFound, "not_found", codes.NotFound},
+	{kv.ErrIsBucket, "is_bucket", codes.FailedPrecondition},
+	{kv.ErrPathIsValue, "path_is_value", codes.FailedPrecondition},
+	{kv.ErrEmptyKey, "empty_key", codes.InvalidArgument},
+	{kv.ErrInvalidKey, "invalid_key", codes.InvalidArgument},
+	{kv.ErrNilValue, "nil_value", codes.InvalidArgument},
+	{kv.ErrInvalidArgument, "invalid_argument", codes.InvalidArgument},
+	{kv.ErrClosed, "closed", codes.Unavailable},
+	{kv.ErrReadOnly, "read_only", codes.PermissionDenied},
+	{kv.ErrExists, "exists", codes.AlreadyExists},
+	{kv.ErrConflict, "conflict", codes.Aborted},
+	{context.Canceled, "canceled", codes.Canceled},
+	{context.DeadlineExceeded, "deadline_exceeded", codes.DeadlineExceeded},
+}
+
+// toStatus converts err into a status error along with a trailer naming
+// the sentinel error that caused it, if any. The message is that of the
+// error wrapped in a *kv.KeyError, since the client knows the op and key.
+func toStatus(err error) (metadata.MD, error) {
+	var keyErr *kv.KeyError
+	if errors.As(err, &keyErr) {
+		err = keyErr.Err
+	}
+
+	for _, s := range sentinels {
+		if errors.Is(err, s.err) {
+			return metadata.Pairs(errorTrailer, s.name), status.Error(s.code, err.Error())
+		}
+	}
+
+	return nil, status.Error(codes.Unknown, err.Error())
+}
+
+// fromStatus rebuilds an error returned by a call along with trailer, so
+// that it can be checked against sentinel errors using errors.Is.
+func fromStatus(err error, trailer metadata.MD) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	names := trailer.Get(errorTrailer)
+	for _, sentinel := range sentinels {
+		if (len(names) > 0 && names[0] == sentinel.name) || (len(names) == 0 && isContext(sentinel.code, s.Code())) {
+			msg := s.Message()
+			if msg == sentinel.err.Error() {
+				return sentinel.err
+			}
+
+			return fmt.Errorf("%w: %s", sentinel.err, strings.TrimPrefix(msg, sentinel.err.Error()+": "))
+		}
+	}
+
+	return err
+}
+
+// isContext tells whether a status code without trailer was caused by a
+// context ending on either side, which grpc reports with these codes.
+func isContext(sentinel, code codes.Code) bool {
+	return sentinel == code && (code == codes.Canceled || code == codes.DeadlineExceeded)
+}
@@ -0,0 +1,196 @@
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/sdeoras/kv"
+	kvgrpc "github.com/sdeoras/kv/grpc"
+	"github.com/sdeoras/kv/kvtest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// newGRPCKv serves db on a local port returning a client along with a func
+// stopping both.
+func newGRPCKv(t *testing.T, db kv.KV) (kv.KV, func()) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer()
+	kvgrpc.RegisterKVServer(srv, kvgrpc.NewServer(db))
+	go func() { _ = srv.Serve(l) }()
+
+	client, closeKv, err := kvgrpc.NewGRPCKv(l.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		srv.Stop()
+		t.Fatal(err)
+	}
+
+	return client, func() {
+		_ = closeKv()
+		srv.Stop()
+	}
+}
+
+func TestGRPCKv(t *testing.T) {
+	kvtest.RunConformance(t, func() (kv.KV, func()) {
+		return newGRPCKv(t, kv.NewMemKv())
+	})
+}
+
+// plainKv hides every interface of a KV other than kv.KV.
+type plainKv struct {
+	kv.KV
+}
+
+func TestGRPCKv_Plain(t *testing.T) {
+	db, done := newGRPCKv(t, plainKv{kv.NewMemKv()})
+	defer done()
+
+	if err := db.Set("/a/b", []byte{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Get("/a"); !errors.Is(err, kv.ErrIsBucket) {
+		t.Fatal("expected ErrIsBucket, got:", err)
+	}
+
+	keys, err := db.Enumerate("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 1 || keys[0] != "/a/b" {
+		t.Fatal("unexpected keys:", keys)
+	}
+}
+
+// blockingIterator iterates over a first key, then waits for release
+// before iterating over a second one.
+type blockingIterator struct {
+	kv.KV
+	release chan struct{}
+}
+
+func (b blockingIterator) Iterate(ctx context.Context, prefix string, f func(key string, val []byte) error) error {
+	return b.IterateKeys(ctx, prefix, func(key string) error { return f(key, []byte{}) })
+}
+
+func (b blockingIterator) IterateKeys(ctx context.Context, prefix string, f func(key string) error) error {
+	if err := f("/first"); err != nil {
+		return err
+	}
+
+	select {
+	case <-b.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return f("/second")
+}
+
+func TestServer_EnumerateStreams(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	srv := grpc.NewServer()
+	kvgrpc.RegisterKVServer(srv, kvgrpc.NewServer(blockingIterator{KV: kv.NewMemKv(), release: release}))
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stream, err := kvgrpc.NewKVClient(conn).Enumerate(context.Background(), &kvgrpc.EnumerateRequest{Key: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first key arrives while the iteration is still under way.
+	for _, expected := range []string{"/first", "/second"} {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.Key != expected {
+			t.Fatalf("expected %s, got: %s", expected, resp.Key)
+		}
+
+		if expected == "/first" {
+			close(release)
+		}
+	}
+
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatal("expected EOF, got:", err)
+	}
+}
+
+// unspecifiedServer sends an event of an unspecified kind ahead of a put on
+// every watch.
+type unspecifiedServer struct {
+	kvgrpc.KVServer
+}
+
+func (unspecifiedServer) Watch(_ *kvgrpc.WatchRequest, stream kvgrpc.KV_WatchServer) error {
+	if err := stream.SendHeader(metadata.Pairs("kv-watching", "true")); err != nil {
+		return err
+	}
+
+	for _, e := range []*kvgrpc.Event{{Key: "/unknown"}, {Kind: kvgrpc.Event_PUT, Key: "/a"}} {
+		if err := stream.Send(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestGRPCKv_WatchUnspecified(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := grpc.NewServer()
+	kvgrpc.RegisterKVServer(srv, unspecifiedServer{kvgrpc.NewServer(kv.NewMemKv())})
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Stop()
+
+	db, closeKv, err := kvgrpc.NewGRPCKv(l.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeKv()
+
+	events, err := db.(kv.Watcher).Watch(context.Background(), "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// events of kinds the client does not know of are skipped.
+	var got []kv.Event
+	for e := range events {
+		got = append(got, e)
+	}
+
+	if len(got) != 1 || got[0].Kind != kv.EventPut || got[0].Key != "/a" {
+		t.Fatal("unexpected events:", got)
+	}
+}
@@ -0,0 +1,753 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: kv.proto
+
+package grpc
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Event_Kind int32
+
+const (
+	Event_EVENT_UNSPECIFIED Event_Kind = 0
+	Event_PUT               Event_Kind = 1
+	Event_DELETE            Event_Kind = 2
+)
+
+var Event_Kind_name = map[int32]string{
+	0: "EVENT_UNSPECIFIED",
+	1: "PUT",
+	2: "DELETE",
+}
+
+var Event_Kind_value = map[string]int32{
+	"EVENT_UNSPECIFIED": 0,
+	"PUT":               1,
+	"DELETE":            2,
+}
+
+func (x Event_Kind) String() string {
+	return proto.EnumName(Event_Kind_name, int32(x))
+}
+
+func (Event_Kind) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_2216fe83c9c12408, []int{9, 0}
+}
+
+type GetRequest struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetRequest) Reset()         { *m = GetRequest{} }
+func (m *GetRequest) String() string { return proto.CompactTextString(m) }
+func (*GetRequest) ProtoMessage()    {}
+func (*GetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2216fe83c9c12408, []int{0}
+}
+
+func (m *GetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetRequest.Unmarshal(m, b)
+}
+func (m *GetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetRequest.Marshal(b, m, deterministic)
+}
+func (m *GetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetRequest.Merge(m, src)
+}
+func (m *GetRequest) XXX_Size() int {
+	return xxx_messageInfo_GetRequest.Size(m)
+}
+func (m *GetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetRequest proto.InternalMessageInfo
+
+func (m *GetRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+type GetResponse struct {
+	Value                []byte   `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetResponse) Reset()         { *m = GetResponse{} }
+func (m *GetResponse) String() string { return proto.CompactTextString(m) }
+func (*GetResponse) ProtoMessage()    {}
+func (*GetResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2216fe83c9c12408, []int{1}
+}
+
+func (m *GetResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetResponse.Unmarshal(m, b)
+}
+func (m *GetResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetResponse.Marshal(b, m, deterministic)
+}
+func (m *GetResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetResponse.Merge(m, src)
+}
+func (m *GetResponse) XXX_Size() int {
+	return xxx_messageInfo_GetResponse.Size(m)
+}
+func (m *GetResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetResponse proto.InternalMessageInfo
+
+func (m *GetResponse) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+type SetRequest struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value                []byte   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetRequest) Reset()         { *m = SetRequest{} }
+func (m *SetRequest) String() string { return proto.CompactTextString(m) }
+func (*SetRequest) ProtoMessage()    {}
+func (*SetRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2216fe83c9c12408, []int{2}
+}
+
+func (m *SetRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetRequest.Unmarshal(m, b)
+}
+func (m *SetRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetRequest.Marshal(b, m, deterministic)
+}
+func (m *SetRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetRequest.Merge(m, src)
+}
+func (m *SetRequest) XXX_Size() int {
+	return xxx_messageInfo_SetRequest.Size(m)
+}
+func (m *SetRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetRequest proto.InternalMessageInfo
+
+func (m *SetRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *SetRequest) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+type SetResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SetResponse) Reset()         { *m = SetResponse{} }
+func (m *SetResponse) String() string { return proto.CompactTextString(m) }
+func (*SetResponse) ProtoMessage()    {}
+func (*SetResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2216fe83c9c12408, []int{3}
+}
+
+func (m *SetResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetResponse.Unmarshal(m, b)
+}
+func (m *SetResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetResponse.Marshal(b, m, deterministic)
+}
+func (m *SetResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetResponse.Merge(m, src)
+}
+func (m *SetResponse) XXX_Size() int {
+	return xxx_messageInfo_SetResponse.Size(m)
+}
+func (m *SetResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetResponse proto.InternalMessageInfo
+
+type DeleteRequest struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteRequest) Reset()         { *m = DeleteRequest{} }
+func (m *DeleteRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteRequest) ProtoMessage()    {}
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2216fe83c9c12408, []int{4}
+}
+
+func (m *DeleteRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteRequest.Unmarshal(m, b)
+}
+func (m *DeleteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteRequest.Merge(m, src)
+}
+func (m *DeleteRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteRequest.Size(m)
+}
+func (m *DeleteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteRequest proto.InternalMessageInfo
+
+func (m *DeleteRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+type DeleteResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteResponse) Reset()         { *m = DeleteResponse{} }
+func (m *DeleteResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteResponse) ProtoMessage()    {}
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2216fe83c9c12408, []int{5}
+}
+
+func (m *DeleteResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteResponse.Unmarshal(m, b)
+}
+func (m *DeleteResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteResponse.Marshal(b, m, deterministic)
+}
+func (m *DeleteResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteResponse.Merge(m, src)
+}
+func (m *DeleteResponse) XXX_Size() int {
+	return xxx_messageInfo_DeleteResponse.Size(m)
+}
+func (m *DeleteResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteResponse proto.InternalMessageInfo
+
+type EnumerateRequest struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EnumerateRequest) Reset()         { *m = EnumerateRequest{} }
+func (m *EnumerateRequest) String() string { return proto.CompactTextString(m) }
+func (*EnumerateRequest) ProtoMessage()    {}
+func (*EnumerateRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2216fe83c9c12408, []int{6}
+}
+
+func (m *EnumerateRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EnumerateRequest.Unmarshal(m, b)
+}
+func (m *EnumerateRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EnumerateRequest.Marshal(b, m, deterministic)
+}
+func (m *EnumerateRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EnumerateRequest.Merge(m, src)
+}
+func (m *EnumerateRequest) XXX_Size() int {
+	return xxx_messageInfo_EnumerateRequest.Size(m)
+}
+func (m *EnumerateRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_EnumerateRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EnumerateRequest proto.InternalMessageInfo
+
+func (m *EnumerateRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+type EnumerateResponse struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EnumerateResponse) Reset()         { *m = EnumerateResponse{} }
+func (m *EnumerateResponse) String() string { return proto.CompactTextString(m) }
+func (*EnumerateResponse) ProtoMessage()    {}
+func (*EnumerateResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2216fe83c9c12408, []int{7}
+}
+
+func (m *EnumerateResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EnumerateResponse.Unmarshal(m, b)
+}
+func (m *EnumerateResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EnumerateResponse.Marshal(b, m, deterministic)
+}
+func (m *EnumerateResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EnumerateResponse.Merge(m, src)
+}
+func (m *EnumerateResponse) XXX_Size() int {
+	return xxx_messageInfo_EnumerateResponse.Size(m)
+}
+func (m *EnumerateResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_EnumerateResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EnumerateResponse proto.InternalMessageInfo
+
+func (m *EnumerateResponse) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+type WatchRequest struct {
+	Prefix               string   `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WatchRequest) Reset()         { *m = WatchRequest{} }
+func (m *WatchRequest) String() string { return proto.CompactTextString(m) }
+func (*WatchRequest) ProtoMessage()    {}
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2216fe83c9c12408, []int{8}
+}
+
+func (m *WatchRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WatchRequest.Unmarshal(m, b)
+}
+func (m *WatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WatchRequest.Marshal(b, m, deterministic)
+}
+func (m *WatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WatchRequest.Merge(m, src)
+}
+func (m *WatchRequest) XXX_Size() int {
+	return xxx_messageInfo_WatchRequest.Size(m)
+}
+func (m *WatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_WatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WatchRequest proto.InternalMessageInfo
+
+func (m *WatchRequest) GetPrefix() string {
+	if m != nil {
+		return m.Prefix
+	}
+	return ""
+}
+
+type Event struct {
+	Kind                 Event_Kind `protobuf:"varint,1,opt,name=kind,proto3,enum=kv.Event_Kind" json:"kind,omitempty"`
+	Key                  string     `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Value                []byte     `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *Event) Reset()         { *m = Event{} }
+func (m *Event) String() string { return proto.CompactTextString(m) }
+func (*Event) ProtoMessage()    {}
+func (*Event) Descriptor() ([]byte, []int) {
+	return fileDescriptor_2216fe83c9c12408, []int{9}
+}
+
+func (m *Event) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Event.Unmarshal(m, b)
+}
+func (m *Event) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Event.Marshal(b, m, deterministic)
+}
+func (m *Event) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Event.Merge(m, src)
+}
+func (m *Event) XXX_Size() int {
+	return xxx_messageInfo_Event.Size(m)
+}
+func (m *Event) XXX_DiscardUnknown() {
+	xxx_messageInfo_Event.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Event proto.InternalMessageInfo
+
+func (m *Event) GetKind() Event_Kind {
+	if m != nil {
+		return m.Kind
+	}
+	return Event_EVENT_UNSPECIFIED
+}
+
+func (m *Event) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *Event) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("kv.Event_Kind", Event_Kind_name, Event_Kind_value)
+	proto.RegisterType((*GetRequest)(nil), "kv.GetRequest")
+	proto.RegisterType((*GetResponse)(nil), "kv.GetResponse")
+	proto.RegisterType((*SetRequest)(nil), "kv.SetRequest")
+	proto.RegisterType((*SetResponse)(nil), "kv.SetResponse")
+	proto.RegisterType((*DeleteRequest)(nil), "kv.DeleteRequest")
+	proto.RegisterType((*DeleteResponse)(nil), "kv.DeleteResponse")
+	proto.RegisterType((*EnumerateRequest)(nil), "kv.EnumerateRequest")
+	proto.RegisterType((*EnumerateResponse)(nil), "kv.EnumerateResponse")
+	proto.RegisterType((*WatchRequest)(nil), "kv.WatchRequest")
+	proto.RegisterType((*Event)(nil), "kv.Event")
+}
+
+func init() { proto.RegisterFile("kv.proto", fileDescriptor_2216fe83c9c12408) }
+
+var fileDescriptor_2216fe83c9c12408 = []byte{
+	// 385 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x52, 0x5d, 0x6f, 0xa2, 0x40,
+	0x14, 0x5d, 0x40, 0xd9, 0xf5, 0xfa, 0xb1, 0x38, 0xd1, 0xcd, 0x86, 0x6c, 0x36, 0xbb, 0xb4, 0x25,
+	0x7d, 0x02, 0x63, 0xfb, 0xd4, 0xc7, 0xd6, 0xa9, 0x31, 0x36, 0xc6, 0x80, 0xda, 0xa4, 0x2f, 0x0d,
+	0xea, 0x54, 0x09, 0x0a, 0x14, 0x06, 0xd2, 0xfe, 0x82, 0xfe, 0xd6, 0xfe, 0x8b, 0x86, 0x11, 0x94,
+	0xda, 0xd6, 0x37, 0xee, 0x3d, 0xe7, 0xdc, 0xcb, 0x3d, 0x67, 0xe0, 0x87, 0x13, 0x6b, 0x7e, 0xe0,
+	0x51, 0x0f, 0xf1, 0x4e, 0xac, 0xfc, 0x05, 0xe8, 0x12, 0x6a, 0x90, 0xc7, 0x88, 0x84, 0x14, 0x49,
+	0x20, 0x38, 0xe4, 0xf9, 0x37, 0xf7, 0x8f, 0x3b, 0x2d, 0x19, 0xc9, 0xa7, 0x72, 0x04, 0x65, 0x86,
+	0x87, 0xbe, 0xe7, 0x86, 0x04, 0x35, 0xa0, 0x18, 0x5b, 0xab, 0x88, 0x30, 0x4a, 0xc5, 0xd8, 0x14,
+	0xca, 0x39, 0x80, 0x79, 0x60, 0xc8, 0x4e, 0xc5, 0xe7, 0x55, 0x55, 0x28, 0x9b, 0xbb, 0xd1, 0xca,
+	0x7f, 0xa8, 0x76, 0xc8, 0x8a, 0x50, 0xf2, 0xf5, 0xcf, 0x48, 0x50, 0xcb, 0x28, 0xa9, 0xe8, 0x18,
+	0x24, 0xec, 0x46, 0x6b, 0x12, 0x58, 0x87, 0x74, 0x27, 0x50, 0xcf, 0xb1, 0xd2, 0x53, 0x3e, 0xd2,
+	0x54, 0xa8, 0xdc, 0x5a, 0x74, 0xb6, 0xcc, 0x06, 0xfd, 0x02, 0xd1, 0x0f, 0xc8, 0x83, 0xfd, 0x94,
+	0x92, 0xd2, 0x4a, 0x79, 0xe1, 0xa0, 0x88, 0x63, 0xe2, 0x52, 0xa4, 0x40, 0xc1, 0xb1, 0xdd, 0x39,
+	0xc3, 0x6b, 0xed, 0x9a, 0xe6, 0xc4, 0x1a, 0x03, 0xb4, 0xbe, 0xed, 0xce, 0x0d, 0x86, 0x65, 0x7b,
+	0xf8, 0x4f, 0xec, 0x10, 0xf2, 0x76, 0xb4, 0xa1, 0x90, 0xa8, 0x50, 0x13, 0xea, 0x78, 0x82, 0x07,
+	0xa3, 0xfb, 0xf1, 0xc0, 0x1c, 0xe2, 0xab, 0xde, 0x75, 0x0f, 0x77, 0xa4, 0x6f, 0xe8, 0x3b, 0x08,
+	0xc3, 0xf1, 0x48, 0xe2, 0x10, 0x80, 0xd8, 0xc1, 0x37, 0x78, 0x84, 0x25, 0xbe, 0xfd, 0xca, 0x01,
+	0xdf, 0x9f, 0x20, 0x15, 0x84, 0x2e, 0xa1, 0x88, 0xed, 0xdf, 0xa5, 0x29, 0xff, 0xdc, 0xd6, 0xe9,
+	0xc9, 0x2a, 0x08, 0x66, 0xc6, 0x33, 0xf7, 0x78, 0xb9, 0x28, 0x90, 0x0e, 0xe2, 0xc6, 0x67, 0x54,
+	0x4f, 0xa0, 0x77, 0xb1, 0xc8, 0x28, 0xdf, 0x4a, 0x05, 0x17, 0x50, 0xda, 0x1a, 0x8c, 0x1a, 0xcc,
+	0x86, 0xbd, 0x54, 0xe4, 0xe6, 0x5e, 0x77, 0xa3, 0x6c, 0x71, 0x48, 0x85, 0x22, 0x73, 0x1d, 0x49,
+	0x09, 0x23, 0x1f, 0x80, 0x5c, 0xda, 0x1a, 0xda, 0xe2, 0x2e, 0xff, 0xdc, 0xc9, 0x0b, 0x9b, 0x2e,
+	0xa3, 0xa9, 0x36, 0xf3, 0xd6, 0x7a, 0x38, 0x27, 0x5e, 0x60, 0x85, 0xba, 0x13, 0xeb, 0x8b, 0xc0,
+	0x9f, 0x4d, 0x45, 0xf6, 0xa4, 0xcf, 0xde, 0x06, 0x00, 0xb6, 0x07, 0xec, 0xd5, 0xde, 0x02, 0x00,
+	0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// KVClient is the client API for KV service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type KVClient interface {
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	Enumerate(ctx context.Context, in *EnumerateRequest, opts ...grpc.CallOption) (KV_EnumerateClient, error)
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KV_WatchClient, error)
+}
+
+type kVClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewKVClient(cc *grpc.ClientConn) KVClient {
+	return &kVClient{cc}
+}
+
+func (c *kVClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, "/kv.KV/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error) {
+	out := new(SetResponse)
+	err := c.cc.Invoke(ctx, "/kv.KV/Set", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/kv.KV/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) Enumerate(ctx context.Context, in *EnumerateRequest, opts ...grpc.CallOption) (KV_EnumerateClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_KV_serviceDesc.Streams[0], "/kv.KV/Enumerate", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVEnumerateClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KV_EnumerateClient interface {
+	Recv() (*EnumerateResponse, error)
+	grpc.ClientStream
+}
+
+type kVEnumerateClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVEnumerateClient) Recv() (*EnumerateResponse, error) {
+	m := new(EnumerateResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KV_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_KV_serviceDesc.Streams[1], "/kv.KV/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KV_WatchClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type kVWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVWatchClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// KVServer is the server API for KV service.
+type KVServer interface {
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Set(context.Context, *SetRequest) (*SetResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	Enumerate(*EnumerateRequest, KV_EnumerateServer) error
+	Watch(*WatchRequest, KV_WatchServer) error
+}
+
+func RegisterKVServer(s *grpc.Server, srv KVServer) {
+	s.RegisterService(&_KV_serviceDesc, srv)
+}
+
+func _KV_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kv.KV/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kv.KV/Set",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).Set(ctx, req.(*SetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kv.KV/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_Enumerate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(EnumerateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVServer).Enumerate(m, &kVEnumerateServer{stream})
+}
+
+type KV_EnumerateServer interface {
+	Send(*EnumerateResponse) error
+	grpc.ServerStream
+}
+
+type kVEnumerateServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVEnumerateServer) Send(m *EnumerateResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _KV_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVServer).Watch(m, &kVWatchServer{stream})
+}
+
+type KV_WatchServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type kVWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVWatchServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _KV_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "kv.KV",
+	HandlerType: (*KVServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _KV_Get_Handler,
+		},
+		{
+			MethodName: "Set",
+			Handler:    _KV_Set_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _KV_Delete_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Enumerate",
+			Handler:       _KV_Enumerate_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _KV_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "kv.proto",
+}
@@ -0,0 +1,204 @@
+// Package grpc serves any kv.KV over gRPC and provides a kv.KV backed by
+// such a server, so that a remote store can be used wherever a local one is.
+// The service is defined in kv.proto, from which kv.pb.go is generated with:
+//
+//	protoc --go_out=plugins=grpc,paths=source_relative:. kv.proto
+package grpc
+
+import (
+	"context"
+
+	"github.com/sdeoras/kv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type server struct {
+	db kv.KV
+}
+
+// NewServer returns a KVServer serving db, which can be registered with
+// RegisterKVServer. Watch requires db to implement kv.Watcher.
+func NewServer(db kv.KV) KVServer {
+	return &server{db: db}
+}
+
+// unaryError converts err into a status error, setting the trailer of
+// the call on ctx.
+func unaryError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	md, err := toStatus(err)
+	if md != nil {
+		_ = grpc.SetTrailer(ctx, md)
+	}
+
+	return err
+}
+
+// streamError converts err into a status error, setting the trailer of stream.
+func streamError(stream grpc.ServerStream, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	md, err := toStatus(err)
+	if md != nil {
+		stream.SetTrailer(md)
+	}
+
+	return err
+}
+
+// withContext returns db as a kv.KVContext, checking ctx upfront only if db
+// does not implement it.
+func (s *server) withContext(ctx context.Context) (kv.KVContext, error) {
+	if c, ok := s.db.(kv.KVContext); ok {
+		return c, nil
+	}
+
+	return withoutContext{s.db}, ctx.Err()
+}
+
+// withoutContext adapts a KV that does not implement KVContext.
+type withoutContext struct {
+	kv.KV
+}
+
+func (w withoutContext) SetContext(_ context.Context, key string, val []byte) error {
+	return w.Set(key, val)
+}
+
+func (w withoutContext) GetContext(_ context.Context, key string) ([]byte, error) {
+	return w.Get(key)
+}
+
+func (w withoutContext) DeleteContext(_ context.Context, key string) error {
+	return w.Delete(key)
+}
+
+func (w withoutContext) EnumerateContext(_ context.Context, key string) ([]string, error) {
+	return w.Enumerate(key)
+}
+
+func (s *server) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	db, err := s.withContext(ctx)
+	if err != nil {
+		return nil, unaryError(ctx, err)
+	}
+
+	val, err := db.GetContext(ctx, req.Key)
+	if err != nil {
+		return nil, unaryError(ctx, err)
+	}
+
+	return &GetResponse{Value: val}, nil
+}
+
+func (s *server) Set(ctx context.Context, req *SetRequest) (*SetResponse, error) {
+	db, err := s.withContext(ctx)
+	if err != nil {
+		return nil, unaryError(ctx, err)
+	}
+
+	// empty values arrive as nil, which clients never send.
+	val := req.Value
+	if val == nil {
+		val = []byte{}
+	}
+
+	if err := db.SetContext(ctx, req.Key, val); err != nil {
+		return nil, unaryError(ctx, err)
+	}
+
+	return &SetResponse{}, nil
+}
+
+func (s *server) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	db, err := s.withContext(ctx)
+	if err != nil {
+		return nil, unaryError(ctx, err)
+	}
+
+	if err := db.DeleteContext(ctx, req.Key); err != nil {
+		return nil, unaryError(ctx, err)
+	}
+
+	return &DeleteResponse{}, nil
+}
+
+// Enumerate streams keys as they are iterated if the KV is a kv.Iterator,
+// and after enumerating all of them otherwise.
+func (s *server) Enumerate(req *EnumerateRequest, stream KV_EnumerateServer) error {
+	ctx := stream.Context()
+	db, err := s.withContext(ctx)
+	if err != nil {
+		return streamError(stream, err)
+	}
+
+	if it, ok := s.db.(kv.Iterator); ok {
+		// errors sending are returned as they are rather than as kv errors.
+		var sendErr error
+		err := it.IterateKeys(ctx, req.Key, func(key string) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			sendErr = stream.Send(&EnumerateResponse{Key: key})
+			return sendErr
+		})
+		if sendErr != nil {
+			return sendErr
+		}
+
+		return streamError(stream, err)
+	}
+
+	keys, err := db.EnumerateContext(ctx, req.Key)
+	if err != nil {
+		return streamError(stream, err)
+	}
+
+	for _, key := range keys {
+		if err := stream.Send(&EnumerateResponse{Key: key}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Watch streams changes until the client cancels. Headers are sent once
+// the watch is set up, so that clients can wait for it to be in place.
+func (s *server) Watch(req *WatchRequest, stream KV_WatchServer) error {
+	w, ok := s.db.(kv.Watcher)
+	if !ok {
+		return status.Error(codes.Unimplemented, "kv.Watcher not implemented")
+	}
+
+	events, err := w.Watch(stream.Context(), req.Prefix)
+	if err != nil {
+		return streamError(stream, err)
+	}
+
+	if err := stream.SendHeader(metadata.Pairs(watchHeader, "true")); err != nil {
+		return err
+	}
+
+	for e := range events {
+		kind := Event_PUT
+		if e.Kind == kv.EventDelete {
+			kind = Event_DELETE
+		}
+
+		if err := stream.Send(&Event{Kind: kind, Key: e.Key, Value: e.Value}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
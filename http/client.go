@@ -0,0 +1,166 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sdeoras/kv"
+)
+
+var _ kv.KVContext = (*httpKv)(nil)
+
+// httpKv implements kv.KV against a server serving NewHandler.
+type httpKv struct {
+	base   *url.URL
+	client *http.Client
+}
+
+// NewHTTPKv provides a new instance of kv.KV backed by a server at baseURL
+// serving NewHandler, e.g. http://localhost:8080 or, for a server listening
+// on a unix socket, unix:///var/run/kv.sock.
+func NewHTTPKv(baseURL string) (kv.KV, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return &httpKv{base: u, client: &http.Client{}}, nil
+	case "unix":
+		sock := u.Path
+		var d net.Dialer
+		t := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return d.DialContext(ctx, "unix", sock)
+			},
+		}
+		return &httpKv{base: &url.URL{Scheme: "http", Host: "unix"}, client: &http.Client{Transport: t}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported url scheme:%s", u.Scheme)
+	}
+}
+
+// keyURL returns the url of key. Each segment is escaped on its own so
+// that escaped slashes within segments survive.
+func (h *httpKv) keyURL(key string, list bool) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(key, "/"), "/")
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+
+	u := *h.base
+	u.RawPath = strings.TrimSuffix(h.base.EscapedPath(), "/") + prefix + "/" + strings.Join(parts, "/")
+	p, err := url.PathUnescape(u.RawPath)
+	if err != nil {
+		return "", err
+	}
+	u.Path = p
+
+	if list {
+		u.RawQuery = "list"
+	}
+
+	return u.String(), nil
+}
+
+// do sends a request for key returning the response body, or the error
+// it was served with.
+func (h *httpKv) do(ctx context.Context, op, method, key string, list bool, body []byte) ([]byte, error) {
+	u, err := h.keyURL(key, list)
+	if err != nil {
+		return nil, &kv.KeyError{Op: op, Key: key, Err: err}
+	}
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, &kv.KeyError{Op: op, Key: key, Err: err}
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, &kv.KeyError{Op: op, Key: key, Err: err}
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &kv.KeyError{Op: op, Key: key, Err: err}
+	}
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, &kv.KeyError{Op: op, Key: key, Err: readError(resp.Header.Get(errorHeader), string(b))}
+	}
+
+	return b, nil
+}
+
+func (h *httpKv) Set(key string, val []byte) error {
+	return h.SetContext(context.Background(), key, val)
+}
+
+func (h *httpKv) SetContext(ctx context.Context, key string, val []byte) error {
+	// an empty body cannot tell nil from empty values apart.
+	if val == nil {
+		return &kv.KeyError{Op: "set", Key: key, Err: kv.ErrNilValue}
+	}
+
+	_, err := h.do(ctx, "set", http.MethodPut, key, false, val)
+	return err
+}
+
+func (h *httpKv) Get(key string) ([]byte, error) {
+	return h.GetContext(context.Background(), key)
+}
+
+func (h *httpKv) GetContext(ctx context.Context, key string) ([]byte, error) {
+	return h.do(ctx, "get", http.MethodGet, key, false, nil)
+}
+
+func (h *httpKv) Delete(key string) error {
+	return h.DeleteContext(context.Background(), key)
+}
+
+func (h *httpKv) DeleteContext(ctx context.Context, key string) error {
+	_, err := h.do(ctx, "delete", http.MethodDelete, key, false, nil)
+	return err
+}
+
+func (h *httpKv) Enumerate(key string) ([]string, error) {
+	return h.EnumerateContext(context.Background(), key)
+}
+
+func (h *httpKv) EnumerateContext(ctx context.Context, key string) ([]string, error) {
+	b, err := h.do(ctx, "enumerate", http.MethodGet, key, true, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, &kv.KeyError{Op: "enumerate", Key: key, Err: err}
+	}
+
+	// keys are joined onto the prefix as given, which the url always
+	// starts with a slash.
+	if !strings.HasPrefix(key, "/") {
+		for i := range keys {
+			keys[i] = strings.TrimPrefix(keys[i], "/")
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sdeoras/kv"
+)
+
+// errorHeader carries the code of a sentinel error in responses.
+const errorHeader = "Kv-Error"
+
+// codes maps sentinel errors of package kv to codes sent in errorHeader
+// along with the HTTP status they are served with.
+var codes = []struct {
+	err    error
+	code   string
+	status int
+}{
+	{kv.ErrNotFound, "not_found", http.StatusNotFound},
+	{kv.ErrIsBucket, "is_bucket", http.StatusConflict},
+	{kv.ErrPathIsValue, "path_is_value", http.StatusConflict},
+	{kv.ErrEmptyKey, "empty_key", http.StatusBadRequest},
+	{kv.ErrInvalidKey, "invalid_key", http.StatusBadRequest},
+	{kv.ErrNilValue, "nil_value", http.StatusBadRequest},
+	{kv.ErrInvalidArgument, "invalid_argument", http.StatusBadRequest},
+	{kv.ErrClosed, "closed", http.StatusServiceUnavailable},
+	{kv.ErrReadOnly, "read_only", http.StatusForbidden},
+	{kv.ErrExists, "exists", http.StatusConflict},
+	{kv.ErrConflict, "conflict", http.StatusPreconditionFailed},
+}
+
+// writeError writes err as a response. The body holds the message of the
+// error wrapped in a *kv.KeyError, since the client knows the op and key.
+func writeError(w http.ResponseWriter, err error) {
+	var keyErr *kv.KeyError
+	if errors.As(err, &keyErr) {
+		err = keyErr.Err
+	}
+
+	status := http.StatusInternalServerError
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			w.Header().Set(errorHeader, c.code)
+			status = c.status
+			break
+		}
+	}
+
+	http.Error(w, err.Error(), status)
+}
+
+// readError rebuilds an error from its code and message, so that it can be
+// checked against sentinel errors using errors.Is.
+func readError(code, msg string) error {
+	msg = strings.TrimSpace(msg)
+	for _, c := range codes {
+		if c.code != code {
+			continue
+		}
+
+		if msg == c.err.Error() {
+			return c.err
+		}
+
+		return fmt.Errorf("%w: %s", c.err, strings.TrimPrefix(msg, c.err.Error()+": "))
+	}
+
+	return errors.New(msg)
+}
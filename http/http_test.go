@@ -0,0 +1,156 @@
+package http_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sdeoras/kv"
+	"github.com/sdeoras/kv/http"
+	"github.com/sdeoras/kv/kvtest"
+)
+
+func TestHTTPKv(t *testing.T) {
+	kvtest.RunConformance(t, func() (kv.KV, func()) {
+		srv := httptest.NewServer(http.NewHandler(kv.NewMemKv()))
+
+		db, err := http.NewHTTPKv(srv.URL)
+		if err != nil {
+			srv.Close()
+			t.Fatal(err)
+		}
+
+		return db, srv.Close
+	})
+}
+
+func TestHTTPKv_UnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sock := filepath.Join(dir, "kv.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &nethttp.Server{Handler: http.NewHandler(kv.NewMemKv())}
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Close()
+
+	db, err := http.NewHTTPKv("unix://" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Set("/a/b/c/myKey", []byte("val")); err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := db.Get("/a/b/c/myKey")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(val) != "val" {
+		t.Fatal("expected val, got:", string(val))
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(http.NewHandler(kv.NewMemKv()))
+	defer srv.Close()
+
+	tests := []struct {
+		method, path string
+		status       int
+		code         string
+	}{
+		{nethttp.MethodGet, "/kv/a", nethttp.StatusNotFound, "not_found"},
+		{nethttp.MethodGet, "/kv/a/../b", nethttp.StatusBadRequest, "invalid_key"},
+		{nethttp.MethodGet, "/kv/", nethttp.StatusBadRequest, "empty_key"},
+		{nethttp.MethodGet, "/other", nethttp.StatusNotFound, ""},
+		{nethttp.MethodPost, "/kv/a", nethttp.StatusMethodNotAllowed, ""},
+	}
+
+	for _, test := range tests {
+		req, err := nethttp.NewRequest(test.method, srv.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := nethttp.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != test.status || resp.Header.Get("Kv-Error") != test.code {
+			t.Fatalf("%s %s: expected %d %q, got: %d %q", test.method, test.path,
+				test.status, test.code, resp.StatusCode, resp.Header.Get("Kv-Error"))
+		}
+	}
+}
+
+func TestHandler_TooLarge(t *testing.T) {
+	db := kv.NewMemKv()
+	srv := httptest.NewServer(http.NewHandler(db))
+	defer srv.Close()
+
+	for _, size := range []int{http.MaxValueSize, http.MaxValueSize + 1} {
+		req, err := nethttp.NewRequest(nethttp.MethodPut, srv.URL+"/kv/a",
+			bytes.NewReader(make([]byte, size)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// a chunked body does not announce its size upfront.
+		req.ContentLength = -1
+
+		resp, err := nethttp.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		expected := nethttp.StatusNoContent
+		if size > http.MaxValueSize {
+			expected = nethttp.StatusRequestEntityTooLarge
+		}
+
+		if resp.StatusCode != expected {
+			t.Fatalf("%d bytes: expected %d, got: %d", size, expected, resp.StatusCode)
+		}
+	}
+
+	if val, err := db.Get("/a"); err != nil {
+		t.Fatal(err)
+	} else if len(val) != http.MaxValueSize {
+		t.Fatal("expected the value that fits to be kept, got:", len(val))
+	}
+}
+
+func TestNewHTTPKv_InvalidURL(t *testing.T) {
+	if _, err := http.NewHTTPKv("ftp://localhost"); err == nil {
+		t.Fatal("expected error for unsupported scheme")
+	}
+
+	var keyErr *kv.KeyError
+	db, err := http.NewHTTPKv("http://127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Get("/a"); !errors.As(err, &keyErr) {
+		t.Fatal("expected *kv.KeyError, got:", err)
+	}
+}
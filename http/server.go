@@ -0,0 +1,150 @@
+// Package http serves any kv.KV over HTTP and provides a kv.KV backed by
+// such a server, so that several processes can share a store, e.g. a bolt
+// file that only one process can hold open.
+//
+// Keys map onto paths under /kv:
+//
+//	GET    /kv/a/b/c        gets a value
+//	PUT    /kv/a/b/c        sets a value to the request body
+//	DELETE /kv/a/b/c        deletes a key or bucket
+//	GET    /kv/a/b?list     enumerates keys under a bucket as a JSON array
+//
+// Values are limited to MaxValueSize bytes, larger ones are rejected with
+// 413 Request Entity Too Large. Errors are served with a status and a
+// Kv-Error header naming the sentinel error of package kv that caused them.
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sdeoras/kv"
+)
+
+// prefix is the path keys are served under.
+const prefix = "/kv"
+
+// MaxValueSize is the size in bytes of the largest value that can be set.
+const MaxValueSize = 32 << 20
+
+type handler struct {
+	db kv.KVContext
+}
+
+// NewHandler returns a handler serving db.
+func NewHandler(db kv.KV) http.Handler {
+	c, ok := db.(kv.KVContext)
+	if !ok {
+		c = withoutContext{db}
+	}
+
+	return &handler{db: c}
+}
+
+// withoutContext adapts a KV that does not implement KVContext, ignoring
+// contexts other than checking them upfront.
+type withoutContext struct {
+	kv.KV
+}
+
+func (w withoutContext) SetContext(ctx context.Context, key string, val []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return w.Set(key, val)
+}
+
+func (w withoutContext) GetContext(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return w.Get(key)
+}
+
+func (w withoutContext) DeleteContext(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return w.Delete(key)
+}
+
+func (w withoutContext) EnumerateContext(ctx context.Context, key string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return w.Enumerate(key)
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the escaped path keeps escaped slashes within key segments apart
+	// from slashes separating them.
+	p := r.URL.EscapedPath()
+	if p != prefix && !strings.HasPrefix(p, prefix+"/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	key, err := url.PathUnescape(strings.TrimPrefix(p, prefix))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	switch r.Method {
+	case http.MethodGet:
+		if _, ok := r.URL.Query()["list"]; ok {
+			keys, err := h.db.EnumerateContext(ctx, key)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(keys)
+			return
+		}
+
+		val, err := h.db.GetContext(ctx, key)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(val)
+	case http.MethodPut:
+		val, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxValueSize))
+		if err != nil {
+			// the reader fails once it has read all it allows and more follows.
+			status := http.StatusBadRequest
+			if len(val) == MaxValueSize {
+				status = http.StatusRequestEntityTooLarge
+			}
+
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		if err := h.db.SetContext(ctx, key, val); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if err := h.db.DeleteContext(ctx, key); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -1,6 +1,9 @@
 package kv
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // CloseFunc is a closure that can be deferred called to close the database.
 type CloseFunc func() error
@@ -15,21 +18,288 @@ type KV interface {
 	// Delete delets a key deleting everything in the tree
 	// if key points to a bucket name.
 	Delete(key string) error
-	// Enumerate lists keys
+	// Enumerate lists keys under a bucket. Keys are sorted path segment
+	// by path segment, i.e. a/b/c sorts before a/b-c.
 	Enumerate(key string) ([]string, error)
 }
 
+// KVContext is a context aware variant of KV. Every backend in this package
+// implements it, so a KV returned by any of the constructors can be asserted
+// to a KVContext in order to propagate deadlines and cancellation into each call.
+type KVContext interface {
+	KV
+	// SetContext is Set honoring ctx.
+	SetContext(ctx context.Context, key string, val []byte) error
+	// GetContext is Get honoring ctx.
+	GetContext(ctx context.Context, key string) ([]byte, error)
+	// DeleteContext is Delete honoring ctx.
+	DeleteContext(ctx context.Context, key string) error
+	// EnumerateContext is Enumerate honoring ctx. Cancellation is checked
+	// while walking the tree, so long enumerations stop early.
+	EnumerateContext(ctx context.Context, key string) ([]string, error)
+}
+
+// Tx is a transaction. Its methods behave like those of KV, with reads
+// observing writes made earlier within the same transaction.
+// A Tx must not be used after the func it was passed to returns.
+type Tx interface {
+	Set(key string, val []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+	Enumerate(key string) ([]string, error)
+}
+
+// Txn is implemented by backends that can apply several operations atomically.
+// Every backend in this package but the directory backend implements it.
+type Txn interface {
+	// Update runs f in a read-write transaction. Changes are committed
+	// if f returns nil and discarded otherwise.
+	Update(f func(tx Tx) error) error
+	// View runs f in a read-only transaction. Writes fail with ErrReadOnly.
+	View(f func(tx Tx) error) error
+}
+
+// Versioned is implemented by backends supporting optimistic concurrency.
+// Every backend in this package implements it, checking the version and
+// writing within a single transaction. Versions change with every write,
+// except on the directory backend where they follow the content of values.
+// The directory backend checks and writes atomically only with respect to
+// the process holding its root open.
+type Versioned interface {
+	// GetWithVersion gets a value along with its current version.
+	GetWithVersion(key string) ([]byte, Version, error)
+	// SetIfVersion sets a value only if key is still at version, returning
+	// ErrConflict otherwise. A zero version requires that key does not exist.
+	SetIfVersion(key string, val []byte, version Version) error
+	// DeleteIfVersion deletes a key only if it is still at version,
+	// returning ErrConflict otherwise.
+	DeleteIfVersion(key string, version Version) error
+}
+
+// Conditional is implemented by backends supporting create-only and
+// update-only writes. Every backend in this package implements it,
+// checking the key and writing within a single transaction, which on the
+// directory backend is atomic only within the process holding its root open.
+type Conditional interface {
+	// SetIfAbsent sets a value only if key does not exist, returning ErrExists otherwise.
+	SetIfAbsent(key string, val []byte) error
+	// Replace sets a value only if key exists, returning ErrNotFound otherwise.
+	Replace(key string, val []byte) error
+}
+
+// Iterator is implemented by backends that can stream keys under a bucket
+// without materializing them. Every backend in this package implements it.
+type Iterator interface {
+	// Iterate calls f for every key under prefix along with its value, walking
+	// a single consistent snapshot. An error returned by f stops the walk and
+	// is returned by Iterate. val belongs to f, which may keep or modify it,
+	// and f must not write to the same store.
+	Iterate(ctx context.Context, prefix string, f func(key string, val []byte) error) error
+	// IterateKeys is Iterate without fetching values.
+	IterateKeys(ctx context.Context, prefix string, f func(key string) error) error
+}
+
+// Pager is implemented by backends that can enumerate keys a page at a time
+// across multiple calls. Every backend in this package implements it.
+type Pager interface {
+	// EnumeratePage lists up to pageSize keys under prefix, starting after the
+	// position token points to. token is empty for the first page. next is the
+	// token for the following page and is empty once all keys have been listed.
+	// A pageSize that is not positive fails with ErrInvalidArgument.
+	EnumeratePage(prefix string, pageSize int, token string) (keys []string, next string, err error)
+}
+
+// Pair is a key along with its value.
+type Pair struct {
+	Key   string
+	Value []byte
+}
+
+// ValueEnumerator is implemented by backends that can list keys along with
+// their values in one pass. Every backend in this package implements it.
+type ValueEnumerator interface {
+	// EnumerateValues lists all leaves under key along with their values
+	// in the same order as Enumerate.
+	EnumerateValues(key string) ([]Pair, error)
+}
+
+// Entry is an immediate child of a bucket as listed by List.
+type Entry struct {
+	// Key is the full key of the child.
+	Key string
+	// Name is the last path segment of Key.
+	Name string
+	// IsBucket is true if the child is a bucket rather than a leaf.
+	IsBucket bool
+	// Children is the number of immediate children of a bucket and zero for a leaf.
+	Children int
+}
+
+// Lister is implemented by backends that can list the immediate children of
+// a bucket, much like ls lists a directory. Every backend in this package
+// implements it.
+type Lister interface {
+	// List lists the immediate children of the bucket prefix points to in
+	// sorted order, without descending into child buckets.
+	List(prefix string) ([]Entry, error)
+}
+
+// Info describes a key as returned by Stat.
+type Info struct {
+	// Key is the key as given to Stat.
+	Key string
+	// Exists is true if key points to a value or a bucket.
+	Exists bool
+	// IsBucket is true if key points to a bucket rather than a value.
+	IsBucket bool
+	// Size is the length of the value of a leaf.
+	Size int
+	// Children is the number of immediate children of a bucket.
+	Children int
+}
+
+// Stater is implemented by backends that can describe a key without fetching
+// its value. Every backend in this package implements it.
+type Stater interface {
+	// Stat describes key. A key that does not exist is not an error, instead
+	// Exists is unset on the returned info.
+	Stat(key string) (Info, error)
+	// Exists reports whether key points to a value or a bucket.
+	Exists(key string) bool
+}
+
+// Expirer is implemented by backends that can expire keys after a time to
+// live. Expired keys are invisible to every read and are reclaimed in the
+// background by memdb and boltKv and lazily on Datastore. Every backend in
+// this package but the directory backend implements it, and so do their
+// transactions, so that a Tx can be asserted to an Expirer in order to set
+// expiries along with other writes.
+type Expirer interface {
+	// SetWithTTL sets a value against a key that expires after ttl.
+	// Setting the key again without a ttl removes the expiry. A ttl that is
+	// not positive fails with ErrInvalidArgument.
+	SetWithTTL(key string, val []byte, ttl time.Duration) error
+	// TTL returns the time left before key expires, or zero if it does not expire.
+	// It fails with ErrIsBucket if key points to a bucket.
+	TTL(key string) (time.Duration, error)
+}
+
+// EventKind tells what kind of change an Event reports.
+type EventKind int
+
+const (
+	// EventPut reports a key being set.
+	EventPut EventKind = iota + 1
+	// EventDelete reports a key being deleted.
+	EventDelete
+)
+
+// String returns the name of an event kind.
+func (k EventKind) String() string {
+	switch k {
+	case EventPut:
+		return "put"
+	case EventDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change to a key as reported by Watch.
+type Event struct {
+	Kind EventKind
+	// Key is the key that changed, with a leading slash.
+	Key string
+	// Value is the new value of a key that was set.
+	Value []byte
+}
+
+// Watcher is implemented by backends that can report changes to keys.
+// Every backend in this package but the directory backend implements it.
+type Watcher interface {
+	// Watch reports changes to keys under prefix, which may also be a single
+	// key, until ctx is done. Deleting a bucket reports a delete for every
+	// key in it. Expiry of keys may not be reported.
+	Watch(ctx context.Context, prefix string) (<-chan Event, error)
+}
+
+// Copier is implemented by backends that can copy and move keys atomically.
+// Every backend in this package but the directory backend implements it.
+type Copier interface {
+	// Copy copies the leaf or bucket at src to dst, keeping expiry times.
+	// dst must not exist, failing with ErrExists otherwise, and must not lie
+	// within src.
+	Copy(src, dst string) error
+	// Move is Copy followed by deleting src, applied atomically.
+	Move(src, dst string) error
+}
+
+// Scanner is implemented by backends that can list keys within a range
+// in sorted order. Every backend in this package implements it.
+type Scanner interface {
+	// Scan lists leaves under prefix along with their values in sorted order,
+	// or in reverse order if reverse is true. Only keys whose path relative to
+	// prefix lies within [start, end) are listed, where an empty bound is open
+	// and paths are compared segment by segment. At most limit pairs are listed
+	// unless limit is zero.
+	Scan(prefix, start, end string, reverse bool, limit int) ([]Pair, error)
+	// ScanKeys is Scan without values.
+	ScanKeys(prefix, start, end string, reverse bool, limit int) ([]string, error)
+}
+
+// Store is a database partitioned into namespaces, each of which is a
+// separate KV. Every backend in this package but the directory backend has one.
+type Store interface {
+	// Namespace returns a KV over namespace ns, creating it if it does not exist.
+	Namespace(ns string) (KV, error)
+	// ListNamespaces lists namespaces in sorted order.
+	ListNamespaces() ([]string, error)
+	// DropNamespace deletes a namespace along with all keys in it. Watchers
+	// of the namespace opened through the store are closed.
+	DropNamespace(ns string) error
+	// RenameNamespace renames namespace old to new, which must not exist.
+	// Watchers of old opened through the store are closed.
+	RenameNamespace(old, new string) error
+}
+
 // NewBoltKv provides a new instance of KV with bolt db as backend.
-func NewBoltKv(dbFile, nameSpace string) (KV, CloseFunc, error) {
-	return newBoltKv(dbFile, nameSpace)
+func NewBoltKv(dbFile, nameSpace string, opts ...Option) (KV, CloseFunc, error) {
+	return newBoltKv(dbFile, nameSpace, opts...)
 }
 
 // NewMemKv provides a new instance of KV with mem db as backend.
-func NewMemKv() KV {
-	return newMemKv()
+func NewMemKv(opts ...Option) KV {
+	return newMemKv(opts...)
 }
 
 // NewDataStoreKv provides a new instance of KV with Google cloud data-store as backend.
-func NewDataStoreKv(ctx context.Context, projectID, nameSpace string) (KV, CloseFunc, error) {
-	return newDataStoreKv(ctx, projectID, nameSpace)
+func NewDataStoreKv(ctx context.Context, projectID, nameSpace string, opts ...Option) (KV, CloseFunc, error) {
+	return newDataStoreKv(ctx, projectID, nameSpace, opts...)
+}
+
+// NewDirKv provides a new instance of KV with a directory tree as backend.
+// The namespace is a directory within root, buckets are directories within it
+// and values are files, which are replaced atomically when written. Segments
+// are escaped so that files stay within root on every platform, but keys
+// differing only in case collide on file systems that ignore case. Locking
+// is per process: only a single process may open root at a time, since
+// writes of other processes are not serialized against those of this one.
+func NewDirKv(root, nameSpace string) (KV, CloseFunc, error) {
+	return newDirKv(root, nameSpace)
+}
+
+// NewBoltStore provides a new instance of Store with bolt db as backend.
+func NewBoltStore(dbFile string, opts ...Option) (Store, CloseFunc, error) {
+	return newBoltStore(dbFile, opts...)
+}
+
+// NewMemStore provides a new instance of Store with mem db as backend.
+func NewMemStore(opts ...Option) Store {
+	return newMemStore(opts...)
+}
+
+// NewDataStoreStore provides a new instance of Store with Google cloud data-store as backend.
+func NewDataStoreStore(ctx context.Context, projectID string, opts ...Option) (Store, CloseFunc, error) {
+	return newDataStoreStore(ctx, projectID, opts...)
 }
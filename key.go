@@ -0,0 +1,160 @@
+package kv
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Key is a parsed key, holding the path segments of a key within a namespace.
+// Segments are held unescaped and an empty Key is the root of a namespace.
+type Key []string
+
+// ParseKey parses a key in the form a/b/c with an optional leading and trailing
+// slash. A slash within a segment is escaped as \/ and a backslash as \\.
+// Empty segments, segments that are . or .., and control characters are
+// rejected with ErrInvalidKey, so that a key can never leave its namespace.
+func ParseKey(s string) (Key, error) {
+	s = strings.TrimPrefix(s, "/")
+	if s == "" {
+		return nil, nil
+	}
+
+	var keys Key
+	var seg strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 == len(s) || (s[i+1] != '\\' && s[i+1] != '/') {
+				return nil, fmt.Errorf("%w: bad escape at %d", ErrInvalidKey, i)
+			}
+			i++
+			seg.WriteByte(s[i])
+		case '/':
+			if i+1 == len(s) {
+				// trailing slash
+				break
+			}
+			keys = append(keys, seg.String())
+			seg.Reset()
+		default:
+			seg.WriteByte(c)
+		}
+	}
+	keys = append(keys, seg.String())
+
+	for _, seg := range keys {
+		if err := checkSegment(seg); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// NewKey returns a Key made of segments, which are taken as is and may
+// therefore contain slashes.
+func NewKey(segments ...string) (Key, error) {
+	for _, seg := range segments {
+		if err := checkSegment(seg); err != nil {
+			return nil, err
+		}
+	}
+
+	return Key(segments), nil
+}
+
+// checkSegment returns ErrInvalidKey unless seg can be a segment of a key.
+func checkSegment(seg string) error {
+	switch seg {
+	case "":
+		return fmt.Errorf("%w: empty segment", ErrInvalidKey)
+	case ".", "..":
+		return fmt.Errorf("%w: segment %q", ErrInvalidKey, seg)
+	}
+
+	for _, r := range seg {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%w: control character %q", ErrInvalidKey, r)
+		}
+	}
+
+	return nil
+}
+
+// String returns the key with a leading slash and escaped segments, which
+// parses back to the same key.
+func (k Key) String() string {
+	return "/" + k.path()
+}
+
+// Less orders keys segment by segment, which is the order in which keys are
+// walked, enumerated and scanned: a bucket comes before the keys under it.
+func (k Key) Less(other Key) bool {
+	for i := 0; i < len(k) && i < len(other); i++ {
+		if k[i] != other[i] {
+			return k[i] < other[i]
+		}
+	}
+
+	return len(k) < len(other)
+}
+
+// path returns escaped segments of the key joined by slashes.
+func (k Key) path() string {
+	escaped := make([]string, len(k))
+	for i, seg := range k {
+		escaped[i] = escapeSegment(seg)
+	}
+
+	return strings.Join(escaped, "/")
+}
+
+var segmentEscaper = strings.NewReplacer(`\`, `\\`, `/`, `\/`)
+
+// escapeSegment escapes slashes and backslashes within a segment.
+func escapeSegment(seg string) string {
+	return segmentEscaper.Replace(seg)
+}
+
+// splitKey parses a key supplied by a caller.
+func splitKey(key string) (Key, error) {
+	return ParseKey(key)
+}
+
+// splitPath splits an escaped path built by this package into its segments.
+func splitPath(p string) Key {
+	keys, _ := ParseKey(p)
+	return keys
+}
+
+// joinKey joins rel, an escaped path, onto prefix as given by a caller,
+// keeping a leading slash of prefix and dropping a trailing one.
+func joinKey(prefix, rel string) string {
+	p := prefix
+	if strings.HasSuffix(p, "/") && !escapedAt(p, len(p)-1) {
+		p = p[:len(p)-1]
+	}
+
+	switch {
+	case rel == "":
+		return p
+	case p == "" && strings.HasPrefix(prefix, "/"):
+		return "/" + rel
+	case p == "":
+		return rel
+	default:
+		return p + "/" + rel
+	}
+}
+
+// escapedAt reports whether the byte at i in s is escaped by an odd number
+// of backslashes before it.
+func escapedAt(s string, i int) bool {
+	n := 0
+	for j := i - 1; j >= 0 && s[j] == '\\'; j-- {
+		n++
+	}
+
+	return n%2 == 1
+}
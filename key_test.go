@@ -0,0 +1,94 @@
+package kv_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sdeoras/kv"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		in  string
+		out kv.Key
+	}{
+		{"", nil},
+		{"/", nil},
+		{"a", kv.Key{"a"}},
+		{"/a/b/c", kv.Key{"a", "b", "c"}},
+		{"/a/b/", kv.Key{"a", "b"}},
+		{`/a\/b/c`, kv.Key{"a/b", "c"}},
+		{`/a\\/b`, kv.Key{`a\`, "b"}},
+		{"/a/.b/..c", kv.Key{"a", ".b", "..c"}},
+	}
+
+	for _, test := range tests {
+		k, err := kv.ParseKey(test.in)
+		if err != nil {
+			t.Fatal(test.in, err)
+		}
+
+		if !reflect.DeepEqual(k, test.out) {
+			t.Fatalf("expected %q for %q, got: %q", test.out, test.in, k)
+		}
+
+		// keys survive a round trip through String
+		if k2, err := kv.ParseKey(k.String()); err != nil || !reflect.DeepEqual(k, k2) {
+			t.Fatalf("expected %q to round trip, got: %q %v", k, k2, err)
+		}
+	}
+}
+
+func TestParseKey_Invalid(t *testing.T) {
+	for _, in := range []string{"..", "../a", "/a/..", "/a/./b", "//", "/a//b", "a\x00b", "a\nb", `a\b`, `a\`} {
+		if _, err := kv.ParseKey(in); !errors.Is(err, kv.ErrInvalidKey) {
+			t.Fatalf("expected ErrInvalidKey for %q, got: %v", in, err)
+		}
+	}
+}
+
+func TestKey_Less(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"/a", "/a/b", true},
+		{"/a/b", "/a", false},
+		{"/a/b", "/a/c", true},
+		{"/a/z", "/a-b", true},
+		{"/a/b", "/a/b", false},
+		{"/", "/a", true},
+	}
+
+	for _, test := range tests {
+		a, err := kv.ParseKey(test.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := kv.ParseKey(test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if less := a.Less(b); less != test.expected {
+			t.Fatalf("%s < %s: expected %v, got: %v", test.a, test.b, test.expected, less)
+		}
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	k, err := kv.NewKey("a/b", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s := k.String(); s != `/a\/b/c` {
+		t.Fatal(`expected /a\/b/c, got:`, s)
+	}
+
+	if _, err := kv.NewKey("a", ".."); !errors.Is(err, kv.ErrInvalidKey) {
+		t.Fatal("expected ErrInvalidKey, got:", err)
+	}
+}
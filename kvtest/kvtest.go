@@ -0,0 +1,2134 @@
+// Package kvtest provides a conformance test suite for implementations of kv.KV
+// and kv.Store.
+//
+// Every backend in package kv is certified against this suite and third party
+// backends can be checked in the same way from their own tests:
+//
+//	func TestMyKv(t *testing.T) {
+//		kvtest.RunConformance(t, func() (kv.KV, func()) {
+//			db := newMyKv()
+//			return db, func() { _ = db.Close() }
+//		})
+//	}
+//
+// Backends that expire keys are checked with RunConformanceWithClock
+// instead, telling the time with the func they are given.
+package kvtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sdeoras/kv"
+)
+
+const (
+	key      = "/a/b/c/myKey"
+	val      = "val"
+	otherKey = "/a/b/c/someOtherKey"
+	otherVal = "someOtherValue"
+)
+
+// Factory returns a new and empty instance of kv.KV along with a func to
+// release it once a test is done.
+type Factory func() (kv.KV, func())
+
+// ClockFactory returns a new and empty instance of kv.KV telling the current
+// time with now, along with a func to release it once a test is done.
+type ClockFactory func(now func() time.Time) (kv.KV, func())
+
+// RunConformance runs the conformance suite against instances of kv.KV
+// returned by factory. Each test gets its own instance. Tests that need to
+// move the clock are skipped, see RunConformanceWithClock.
+func RunConformance(t *testing.T, factory Factory) {
+	runConformance(t, func(*Clock) (kv.KV, func()) { return factory() }, false)
+}
+
+// RunConformanceWithClock runs the conformance suite like RunConformance,
+// giving each instance a Clock of its own, so that tests can let keys
+// expire without waiting.
+func RunConformanceWithClock(t *testing.T, factory ClockFactory) {
+	runConformance(t, func(clock *Clock) (kv.KV, func()) { return factory(clock.Now) }, true)
+}
+
+// runConformance runs the suite against instances returned by factory,
+// running tests that move the clock only if clocked is set.
+func runConformance(t *testing.T, factory func(clock *Clock) (kv.KV, func()), clocked bool) {
+	tests := []struct {
+		name string
+		f    func(t *testing.T, db kv.KV)
+	}{
+		{"GetSet", testGetSet},
+		{"GetSetLeadingSlash", testGetSetLeadingSlash},
+		{"GetSetOverwrite", testGetSetOverwrite},
+		{"GetSetCopiesValue", testGetSetCopiesValue},
+		{"GetWrongKey", testGetWrongKey},
+		{"GetWrongBucket", testGetWrongBucket},
+		{"GetBucket", testGetBucket},
+		{"SetEmptyKey", testSetEmptyKey},
+		{"GetEmptyKey", testGetEmptyKey},
+		{"SetNilValue", testSetNilValue},
+		{"SetZeroValue", testSetZeroValue},
+		{"SetAcrossValue", testSetAcrossValue},
+		{"SetOnBucket", testSetOnBucket},
+		{"DeleteKey", testDeleteKey},
+		{"DeleteTree", testDeleteTree},
+		{"DeleteDeletedKey", testDeleteDeletedKey},
+		{"DeleteEmptyKey", testDeleteEmptyKey},
+		{"InvalidKey", testInvalidKey},
+		{"EscapedKey", testEscapedKey},
+		{"Enumerate", testEnumerate},
+		{"EnumerateOrder", testEnumerateOrder},
+		{"EnumerateLeaf", testEnumerateLeaf},
+		{"EnumerateWrongBucket", testEnumerateWrongBucket},
+		{"DeleteEnumerate", testDeleteEnumerate},
+		{"DeleteAllEnumerate", testDeleteAllEnumerate},
+		{"KeyError", testKeyError},
+		{"Context", testContext},
+		{"TxnUpdate", testTxnUpdate},
+		{"TxnRollback", testTxnRollback},
+		{"TxnReadOwnWrites", testTxnReadOwnWrites},
+		{"TxnView", testTxnView},
+		{"Versioned", testVersioned},
+		{"VersionedCreate", testVersionedCreate},
+		{"VersionedDelete", testVersionedDelete},
+		{"SetIfAbsent", testSetIfAbsent},
+		{"Replace", testReplace},
+		{"Iterate", testIterate},
+		{"IterateStop", testIterateStop},
+		{"IterateModify", testIterateModify},
+		{"IterateKeys", testIterateKeys},
+		{"EnumeratePage", testEnumeratePage},
+		{"EnumeratePageInvalid", testEnumeratePageInvalid},
+		{"Scan", testScan},
+		{"ScanNested", testScanNested},
+		{"EnumerateValues", testEnumerateValues},
+		{"EnumerateValuesLeaf", testEnumerateValuesLeaf},
+		{"List", testList},
+		{"ListLeaf", testListLeaf},
+		{"Stat", testStat},
+		{"StatMissing", testStatMissing},
+		{"TTL", testTTL},
+		{"TTLTxn", testTTLTxn},
+		{"Watch", testWatch},
+		{"WatchKey", testWatchKey},
+		{"WatchCancel", testWatchCancel},
+		{"Copy", testCopy},
+		{"CopyLeaf", testCopyLeaf},
+		{"CopyTTL", testCopyTTL},
+		{"CopyEmptyBucket", testCopyEmptyBucket},
+		{"Move", testMove},
+		{"MoveInvalid", testMoveInvalid},
+	}
+
+	clockTests := []struct {
+		name string
+		f    func(t *testing.T, db kv.KV, clock *Clock)
+	}{
+		{"TTLExpire", testTTLExpire},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			db, done := factory(NewClock())
+			defer done()
+			test.f(t, db)
+		})
+	}
+
+	for _, test := range clockTests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if !clocked {
+				t.Skip("clock not injected")
+			}
+
+			clock := NewClock()
+			db, done := factory(clock)
+			defer done()
+			test.f(t, db, clock)
+		})
+	}
+}
+
+// Clock is a clock that only moves when told to.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a Clock set to a fixed time.
+func NewClock() *Clock {
+	return &Clock{now: time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)}
+}
+
+// Now tells the time the clock is set to.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Add moves the clock by d, which may be negative.
+func (c *Clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// setKeys sets key and otherKey in the same bucket.
+func setKeys(t *testing.T, db kv.KV) {
+	t.Helper()
+
+	// set something
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	// set something else in the same bucket
+	if err := db.Set(otherKey, []byte(otherVal)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectErr fails the test unless err matches target.
+func expectErr(t *testing.T, err, target error) {
+	t.Helper()
+
+	if !errors.Is(err, target) {
+		t.Fatalf("expected error %q, got: %v", target, err)
+	}
+}
+
+// expectKeys fails the test unless keys contains exactly the expected keys in any order.
+func expectKeys(t *testing.T, keys []string, expected ...string) {
+	t.Helper()
+
+	keys = append([]string(nil), keys...)
+	expected = append([]string(nil), expected...)
+	sort.Strings(keys)
+	sort.Strings(expected)
+
+	if len(keys) != len(expected) {
+		t.Fatalf("expected keys %v, got: %v", expected, keys)
+	}
+
+	for i := range keys {
+		if keys[i] != expected[i] {
+			t.Fatalf("expected keys %v, got: %v", expected, keys)
+		}
+	}
+}
+
+// expectOrder fails the test unless keys equals expected in order.
+func expectOrder(t *testing.T, keys []string, expected ...string) {
+	t.Helper()
+
+	if len(keys) != len(expected) {
+		t.Fatalf("expected keys %v, got: %v", expected, keys)
+	}
+
+	for i := range keys {
+		if keys[i] != expected[i] {
+			t.Fatalf("expected keys %v, got: %v", expected, keys)
+		}
+	}
+}
+
+func testGetSet(t *testing.T, db kv.KV) {
+	// set something
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	// get that thing
+	if retVal, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	} else if string(retVal) != val {
+		t.Fatal("expected", val, "got:", string(retVal))
+	}
+}
+
+func testGetSetLeadingSlash(t *testing.T, db kv.KV) {
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	// leading slash is ignored
+	if retVal, err := db.Get(key[1:]); err != nil {
+		t.Fatal(err)
+	} else if string(retVal) != val {
+		t.Fatal("expected", val, "got:", string(retVal))
+	}
+}
+
+func testGetSetOverwrite(t *testing.T, db kv.KV) {
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Set(key, []byte(otherVal)); err != nil {
+		t.Fatal(err)
+	}
+
+	if retVal, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	} else if string(retVal) != otherVal {
+		t.Fatal("expected", otherVal, "got:", string(retVal))
+	}
+}
+
+func testGetSetCopiesValue(t *testing.T, db kv.KV) {
+	b := []byte(val)
+	if err := db.Set(key, b); err != nil {
+		t.Fatal(err)
+	}
+
+	// neither the slice passed to Set nor the one returned by Get
+	// should alias the stored value.
+	b[0] = 'x'
+	retVal, err := db.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(retVal) != val {
+		t.Fatal("expected", val, "got:", string(retVal))
+	}
+
+	retVal[0] = 'x'
+	if retVal, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	} else if string(retVal) != val {
+		t.Fatal("expected", val, "got:", string(retVal))
+	}
+}
+
+func testGetWrongKey(t *testing.T, db kv.KV) {
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := db.Get("wrongKey")
+	expectErr(t, err, kv.ErrNotFound)
+}
+
+func testGetWrongBucket(t *testing.T, db kv.KV) {
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := db.Get("/a/b/d/this")
+	expectErr(t, err, kv.ErrNotFound)
+}
+
+func testGetBucket(t *testing.T, db kv.KV) {
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := db.Get("/a/b/c")
+	expectErr(t, err, kv.ErrIsBucket)
+}
+
+func testSetEmptyKey(t *testing.T, db kv.KV) {
+	expectErr(t, db.Set("", []byte(val)), kv.ErrEmptyKey)
+	expectErr(t, db.Set("/", []byte(val)), kv.ErrEmptyKey)
+}
+
+func testGetEmptyKey(t *testing.T, db kv.KV) {
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := db.Get("")
+	expectErr(t, err, kv.ErrEmptyKey)
+}
+
+func testSetNilValue(t *testing.T, db kv.KV) {
+	expectErr(t, db.Set(key, nil), kv.ErrNilValue)
+}
+
+func testSetZeroValue(t *testing.T, db kv.KV) {
+	if err := db.Set(key, []byte{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if retVal, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	} else if retVal == nil {
+		t.Fatal("expected val to be zero length but not nil, got nil")
+	} else if len(retVal) != 0 {
+		t.Fatal("expected val to be zero length, got:", len(retVal))
+	}
+}
+
+func testSetAcrossValue(t *testing.T, db kv.KV) {
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	expectErr(t, db.Set(key+"/child", []byte(val)), kv.ErrPathIsValue)
+
+	_, err := db.Get(key + "/child")
+	expectErr(t, err, kv.ErrPathIsValue)
+}
+
+func testSetOnBucket(t *testing.T, db kv.KV) {
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	expectErr(t, db.Set("/a/b", []byte(val)), kv.ErrIsBucket)
+
+	// the tree below the bucket must be left intact
+	if _, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testDeleteKey(t *testing.T, db kv.KV) {
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	// now delete the key
+	if err := db.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+
+	// now ensure you can't get that thing
+	_, err := db.Get(key)
+	expectErr(t, err, kv.ErrNotFound)
+}
+
+func testDeleteTree(t *testing.T, db kv.KV) {
+	setKeys(t, db)
+
+	// now delete the tree
+	if err := db.Delete("/a/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	// now ensure you can't get either thing
+	for _, k := range []string{key, otherKey, "/a/b/c"} {
+		_, err := db.Get(k)
+		expectErr(t, err, kv.ErrNotFound)
+	}
+}
+
+func testDeleteDeletedKey(t *testing.T, db kv.KV) {
+	setKeys(t, db)
+
+	if err := db.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+
+	expectErr(t, db.Delete(key), kv.ErrNotFound)
+}
+
+func testDeleteEmptyKey(t *testing.T, db kv.KV) {
+	setKeys(t, db)
+
+	expectErr(t, db.Delete(""), kv.ErrEmptyKey)
+	expectErr(t, db.Delete("/"), kv.ErrEmptyKey)
+}
+
+func testInvalidKey(t *testing.T, db kv.KV) {
+	setKeys(t, db)
+
+	for _, k := range []string{"../other/secret", "/a/../../x", "/a//b", "/a/./b", "/a/b\x01c", `/a/b\c`} {
+		expectErr(t, db.Set(k, []byte(val)), kv.ErrInvalidKey)
+
+		_, err := db.Get(k)
+		expectErr(t, err, kv.ErrInvalidKey)
+
+		expectErr(t, db.Delete(k), kv.ErrInvalidKey)
+
+		_, err = db.Enumerate(k)
+		expectErr(t, err, kv.ErrInvalidKey)
+	}
+
+	keys, err := db.Enumerate("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, keys, key, otherKey)
+}
+
+func testEscapedKey(t *testing.T, db kv.KV) {
+	// x/y is a single segment here
+	k := `/a/x\/y`
+	if err := db.Set(k, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Set("/a/x/z", []byte(otherVal)); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := db.Get(k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(v) != val {
+		t.Fatal("expected", val, "got:", string(v))
+	}
+
+	keys, err := db.Enumerate("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOrder(t, keys, "/a/x/z", k)
+
+	keys, err = db.Enumerate("/a/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, keys, "/a/x/z")
+}
+
+func testEnumerate(t *testing.T, db kv.KV) {
+	setKeys(t, db)
+
+	// enumerating any bucket along the path lists both keys
+	// joined onto the prefix used for enumeration.
+	for _, prefix := range []string{"/", "/a", "/a/b", "/a/b/", "/a/b/c"} {
+		keys, err := db.Enumerate(prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectOrder(t, keys, key, otherKey)
+	}
+
+	keys, err := db.Enumerate("a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectOrder(t, keys, key[1:], otherKey[1:])
+}
+
+func testEnumerateOrder(t *testing.T, db kv.KV) {
+	// keys are sorted segment by segment, so a/b/... sorts before a/b-c/...
+	// even though '-' sorts before '/', and by unescaped segments, so that
+	// a/b\/c sorts before a/ba.
+	expected := []string{"/a/B", "/a/b/c/myKey", "/a/b/c/someOtherKey", "/a/b/cc", "/a/b-c/x", `/a/b\/c`, "/a/ba"}
+	for _, i := range []int{3, 0, 6, 5, 2, 4, 1} {
+		if err := db.Set(expected[i], []byte(val)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// repeated calls return the same order
+	for i := 0; i < 3; i++ {
+		keys, err := db.Enumerate("/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectOrder(t, keys, expected...)
+	}
+
+	if it, ok := db.(kv.Iterator); ok {
+		var keys []string
+		if err := it.IterateKeys(context.Background(), "/a", func(key string) error {
+			keys = append(keys, key)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		expectOrder(t, keys, expected...)
+	}
+
+	if p, ok := db.(kv.Pager); ok {
+		var keys []string
+		token := ""
+		for i := 0; i <= len(expected); i++ {
+			page, next, err := p.EnumeratePage("/a", 2, token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			keys = append(keys, page...)
+			if next == "" {
+				break
+			}
+			token = next
+		}
+
+		expectOrder(t, keys, expected...)
+	}
+
+	if s, ok := db.(kv.Scanner); ok {
+		keys, err := s.ScanKeys("/a", "", "", false, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectOrder(t, keys, expected...)
+	}
+}
+
+func testEnumerateLeaf(t *testing.T, db kv.KV) {
+	setKeys(t, db)
+
+	_, err := db.Enumerate(key)
+	expectErr(t, err, kv.ErrPathIsValue)
+}
+
+func testEnumerateWrongBucket(t *testing.T, db kv.KV) {
+	setKeys(t, db)
+
+	_, err := db.Enumerate("/a/d")
+	expectErr(t, err, kv.ErrNotFound)
+}
+
+func testDeleteEnumerate(t *testing.T, db kv.KV) {
+	setKeys(t, db)
+
+	if err := db.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := db.Enumerate("/a/b/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectKeys(t, keys, otherKey)
+}
+
+func testDeleteAllEnumerate(t *testing.T, db kv.KV) {
+	setKeys(t, db)
+
+	if err := db.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Delete(otherKey); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := db.Enumerate("/a/b/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectKeys(t, keys)
+}
+
+func testKeyError(t *testing.T, db kv.KV) {
+	setKeys(t, db)
+
+	var keyErr *kv.KeyError
+	if err := db.Delete("/a/b/d"); !errors.As(err, &keyErr) {
+		t.Fatal("expected *kv.KeyError, got:", err)
+	}
+
+	if keyErr.Op != "delete" || keyErr.Key != "/a/b/d" {
+		t.Fatal("unexpected op or key in error:", keyErr)
+	}
+}
+
+func testContext(t *testing.T, db kv.KV) {
+	c, ok := db.(kv.KVContext)
+	if !ok {
+		t.Skip("kv.KVContext not implemented")
+	}
+
+	setKeys(t, db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.GetContext(ctx, key)
+	expectErr(t, err, context.Canceled)
+	expectErr(t, c.SetContext(ctx, key, []byte(val)), context.Canceled)
+	expectErr(t, c.DeleteContext(ctx, key), context.Canceled)
+	_, err = c.EnumerateContext(ctx, "/a")
+	expectErr(t, err, context.Canceled)
+
+	// a live context works as usual
+	if retVal, err := c.GetContext(context.Background(), key); err != nil {
+		t.Fatal(err)
+	} else if string(retVal) != val {
+		t.Fatal("expected", val, "got:", string(retVal))
+	}
+}
+
+// txn skips the test unless db implements kv.Txn.
+func txn(t *testing.T, db kv.KV) kv.Txn {
+	t.Helper()
+
+	txn, ok := db.(kv.Txn)
+	if !ok {
+		t.Skip("kv.Txn not implemented")
+	}
+
+	return txn
+}
+
+func testTxnUpdate(t *testing.T, db kv.KV) {
+	txn := txn(t, db)
+
+	if err := txn.Update(func(tx kv.Tx) error {
+		if err := tx.Set(key, []byte(val)); err != nil {
+			return err
+		}
+		return tx.Set(otherKey, []byte(otherVal))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := db.Enumerate("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectKeys(t, keys, key, otherKey)
+}
+
+func testTxnRollback(t *testing.T, db kv.KV) {
+	txn := txn(t, db)
+
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	errAbort := errors.New("abort")
+	err := txn.Update(func(tx kv.Tx) error {
+		if err := tx.Set(otherKey, []byte(otherVal)); err != nil {
+			return err
+		}
+		if err := tx.Set(key, []byte(otherVal)); err != nil {
+			return err
+		}
+		if err := tx.Delete("/a/b"); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	expectErr(t, err, errAbort)
+
+	// nothing done within the transaction is visible
+	if retVal, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	} else if string(retVal) != val {
+		t.Fatal("expected", val, "got:", string(retVal))
+	}
+
+	_, err = db.Get(otherKey)
+	expectErr(t, err, kv.ErrNotFound)
+}
+
+func testTxnReadOwnWrites(t *testing.T, db kv.KV) {
+	txn := txn(t, db)
+
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := txn.Update(func(tx kv.Tx) error {
+		if err := tx.Set(otherKey, []byte(otherVal)); err != nil {
+			return err
+		}
+
+		if retVal, err := tx.Get(otherKey); err != nil {
+			return err
+		} else if string(retVal) != otherVal {
+			t.Fatal("expected", otherVal, "got:", string(retVal))
+		}
+
+		keys, err := tx.Enumerate("/a")
+		if err != nil {
+			return err
+		}
+		expectKeys(t, keys, key, otherKey)
+
+		if err := tx.Delete(key); err != nil {
+			return err
+		}
+
+		_, err = tx.Get(key)
+		expectErr(t, err, kv.ErrNotFound)
+
+		keys, err = tx.Enumerate("/a")
+		if err != nil {
+			return err
+		}
+		expectKeys(t, keys, otherKey)
+
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := db.Enumerate("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectKeys(t, keys, otherKey)
+}
+
+func testTxnView(t *testing.T, db kv.KV) {
+	txn := txn(t, db)
+
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := txn.View(func(tx kv.Tx) error {
+		if retVal, err := tx.Get(key); err != nil {
+			return err
+		} else if string(retVal) != val {
+			t.Fatal("expected", val, "got:", string(retVal))
+		}
+
+		expectErr(t, tx.Set(otherKey, []byte(otherVal)), kv.ErrReadOnly)
+		expectErr(t, tx.Delete(key), kv.ErrReadOnly)
+
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// versioned skips the test unless db implements kv.Versioned.
+func versioned(t *testing.T, db kv.KV) kv.Versioned {
+	t.Helper()
+
+	v, ok := db.(kv.Versioned)
+	if !ok {
+		t.Skip("kv.Versioned not implemented")
+	}
+
+	return v
+}
+
+func testVersioned(t *testing.T, db kv.KV) {
+	v := versioned(t, db)
+
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	retVal, version, err := v.GetWithVersion(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(retVal) != val || version == "" {
+		t.Fatal("unexpected value or version:", string(retVal), version)
+	}
+
+	// a write made at the current version succeeds...
+	if err := v.SetIfVersion(key, []byte(otherVal), version); err != nil {
+		t.Fatal(err)
+	}
+
+	// ...after which the old version is stale.
+	expectErr(t, v.SetIfVersion(key, []byte(val), version), kv.ErrConflict)
+
+	if retVal, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	} else if string(retVal) != otherVal {
+		t.Fatal("expected", otherVal, "got:", string(retVal))
+	}
+}
+
+func testVersionedCreate(t *testing.T, db kv.KV) {
+	v := versioned(t, db)
+
+	// zero version creates a key only if it does not exist
+	if err := v.SetIfVersion(key, []byte(val), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	expectErr(t, v.SetIfVersion(key, []byte(otherVal), ""), kv.ErrConflict)
+
+	_, _, err := v.GetWithVersion(otherKey)
+	expectErr(t, err, kv.ErrNotFound)
+}
+
+func testVersionedDelete(t *testing.T, db kv.KV) {
+	v := versioned(t, db)
+
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, version, err := v.GetWithVersion(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Set(key, []byte(otherVal)); err != nil {
+		t.Fatal(err)
+	}
+
+	expectErr(t, v.DeleteIfVersion(key, version), kv.ErrConflict)
+
+	_, version, err = v.GetWithVersion(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.DeleteIfVersion(key, version); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Get(key)
+	expectErr(t, err, kv.ErrNotFound)
+}
+
+// conditional skips the test unless db implements kv.Conditional.
+func conditional(t *testing.T, db kv.KV) kv.Conditional {
+	t.Helper()
+
+	c, ok := db.(kv.Conditional)
+	if !ok {
+		t.Skip("kv.Conditional not implemented")
+	}
+
+	return c
+}
+
+func testSetIfAbsent(t *testing.T, db kv.KV) {
+	c := conditional(t, db)
+
+	if err := c.SetIfAbsent(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	expectErr(t, c.SetIfAbsent(key, []byte(otherVal)), kv.ErrExists)
+	expectErr(t, c.SetIfAbsent("/a/b", []byte(otherVal)), kv.ErrIsBucket)
+
+	if retVal, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	} else if string(retVal) != val {
+		t.Fatal("expected", val, "got:", string(retVal))
+	}
+}
+
+func testReplace(t *testing.T, db kv.KV) {
+	c := conditional(t, db)
+
+	expectErr(t, c.Replace(key, []byte(val)), kv.ErrNotFound)
+
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Replace(key, []byte(otherVal)); err != nil {
+		t.Fatal(err)
+	}
+
+	if retVal, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	} else if string(retVal) != otherVal {
+		t.Fatal("expected", otherVal, "got:", string(retVal))
+	}
+}
+
+// iterator skips the test unless db implements kv.Iterator.
+func iterator(t *testing.T, db kv.KV) kv.Iterator {
+	t.Helper()
+
+	it, ok := db.(kv.Iterator)
+	if !ok {
+		t.Skip("kv.Iterator not implemented")
+	}
+
+	return it
+}
+
+func testIterate(t *testing.T, db kv.KV) {
+	it := iterator(t, db)
+	setKeys(t, db)
+
+	vals := make(map[string]string)
+	if err := it.Iterate(context.Background(), "/a/b", func(key string, val []byte) error {
+		vals[key] = string(val)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vals) != 2 || vals[key] != val || vals[otherKey] != otherVal {
+		t.Fatal("unexpected keys and values:", vals)
+	}
+
+	err := it.Iterate(context.Background(), key, func(string, []byte) error { return nil })
+	expectErr(t, err, kv.ErrPathIsValue)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = it.Iterate(ctx, "/a", func(string, []byte) error { return nil })
+	expectErr(t, err, context.Canceled)
+}
+
+func testIterateModify(t *testing.T, db kv.KV) {
+	it := iterator(t, db)
+	setKeys(t, db)
+
+	// values passed to f are its own to keep and modify.
+	var kept [][]byte
+	if err := it.Iterate(context.Background(), "/a/b", func(_ string, val []byte) error {
+		if len(val) > 0 {
+			val[0] = 'X'
+		}
+		kept = append(kept, val)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(kept) != 2 || string(kept[0]) != "X"+val[1:] {
+		t.Fatal("unexpected values:", kept)
+	}
+
+	if retVal, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	} else if string(retVal) != val {
+		t.Fatal("expected", val, "got:", string(retVal))
+	}
+}
+
+func testIterateStop(t *testing.T, db kv.KV) {
+	it := iterator(t, db)
+	setKeys(t, db)
+
+	errStop := errors.New("stop")
+	n := 0
+	err := it.Iterate(context.Background(), "/", func(string, []byte) error {
+		n++
+		return errStop
+	})
+	expectErr(t, err, errStop)
+
+	if n != 1 {
+		t.Fatal("expected iteration to stop after first key, got calls:", n)
+	}
+}
+
+func testIterateKeys(t *testing.T, db kv.KV) {
+	it := iterator(t, db)
+	setKeys(t, db)
+
+	var keys []string
+	if err := it.IterateKeys(context.Background(), "/a", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectKeys(t, keys, key, otherKey)
+}
+
+// pager skips the test unless db implements kv.Pager.
+func pager(t *testing.T, db kv.KV) kv.Pager {
+	t.Helper()
+
+	p, ok := db.(kv.Pager)
+	if !ok {
+		t.Skip("kv.Pager not implemented")
+	}
+
+	return p
+}
+
+func testEnumeratePage(t *testing.T, db kv.KV) {
+	p := pager(t, db)
+
+	var expected []string
+	for i := 0; i < 7; i++ {
+		k := fmt.Sprintf("/a/b/%d/key%d", i%3, i)
+		if err := db.Set(k, []byte(val)); err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, k)
+	}
+
+	// walk pages of 3 keys, keys are never repeated across pages
+	var keys []string
+	token := ""
+	for i := 0; ; i++ {
+		if i > len(expected) {
+			t.Fatal("too many pages")
+		}
+
+		page, next, err := p.EnumeratePage("/a", 3, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(page) > 3 {
+			t.Fatal("expected at most 3 keys on a page, got:", len(page))
+		}
+
+		keys = append(keys, page...)
+		if next == "" {
+			break
+		}
+		token = next
+	}
+
+	expectKeys(t, keys, expected...)
+}
+
+func testEnumeratePageInvalid(t *testing.T, db kv.KV) {
+	p := pager(t, db)
+	setKeys(t, db)
+
+	_, _, err := p.EnumeratePage("/a", 1, "not a token")
+	expectErr(t, err, kv.ErrInvalidToken)
+
+	_, _, err = p.EnumeratePage("/a", 0, "")
+	expectErr(t, err, kv.ErrInvalidArgument)
+
+	_, _, err = p.EnumeratePage(key, 1, "")
+	expectErr(t, err, kv.ErrPathIsValue)
+}
+
+// scanner skips the test unless db implements kv.Scanner.
+func scanner(t *testing.T, db kv.KV) kv.Scanner {
+	t.Helper()
+
+	s, ok := db.(kv.Scanner)
+	if !ok {
+		t.Skip("kv.Scanner not implemented")
+	}
+
+	return s
+}
+
+func testScan(t *testing.T, db kv.KV) {
+	s := scanner(t, db)
+
+	for _, day := range []string{"17", "15", "19", "16", "18"} {
+		if err := db.Set("/events/2026-10-"+day+"T10:00", []byte(day)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := s.ScanKeys("/events", "2026-10-16", "2026-10-18", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOrder(t, keys, "/events/2026-10-16T10:00", "/events/2026-10-17T10:00")
+
+	keys, err = s.ScanKeys("/events", "", "", true, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOrder(t, keys, "/events/2026-10-19T10:00", "/events/2026-10-18T10:00")
+
+	keys, err = s.ScanKeys("/events", "", "2026-10-17T10:00", true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOrder(t, keys, "/events/2026-10-16T10:00", "/events/2026-10-15T10:00")
+
+	pairs, err := s.Scan("/events", "2026-10-18", "", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 2 || pairs[0].Key != "/events/2026-10-18T10:00" ||
+		string(pairs[0].Value) != "18" || string(pairs[1].Value) != "19" {
+		t.Fatal("unexpected pairs:", pairs)
+	}
+
+	_, err = s.Scan("/events/2026-10-18T10:00", "", "", false, 0)
+	expectErr(t, err, kv.ErrPathIsValue)
+}
+
+func testScanNested(t *testing.T, db kv.KV) {
+	s := scanner(t, db)
+	setKeys(t, db)
+
+	for _, k := range []string{"/a/b/d/x", "/a/e", "/a/b/cc"} {
+		if err := db.Set(k, []byte(val)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := s.ScanKeys("/a", "", "", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOrder(t, keys, key, otherKey, "/a/b/cc", "/a/b/d/x", "/a/e")
+
+	// bounds are compared segment by segment
+	keys, err = s.ScanKeys("/a", "b/c/someOtherKey", "b/d", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOrder(t, keys, otherKey, "/a/b/cc")
+
+	keys, err = s.ScanKeys("/a", "b/c/someOtherKey", "b/d", true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOrder(t, keys, "/a/b/cc", otherKey)
+
+	keys, err = s.ScanKeys("/a", "b/d", "", true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOrder(t, keys, "/a/e", "/a/b/d/x")
+}
+
+// valueEnumerator skips the test unless db implements kv.ValueEnumerator.
+func valueEnumerator(t *testing.T, db kv.KV) kv.ValueEnumerator {
+	t.Helper()
+
+	e, ok := db.(kv.ValueEnumerator)
+	if !ok {
+		t.Skip("kv.ValueEnumerator not implemented")
+	}
+
+	return e
+}
+
+func testEnumerateValues(t *testing.T, db kv.KV) {
+	e := valueEnumerator(t, db)
+	setKeys(t, db)
+
+	if err := db.Set("/a/b/d", []byte("d")); err != nil {
+		t.Fatal(err)
+	}
+
+	pairs, err := e.EnumerateValues("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pairs are listed in the same order as Enumerate lists keys
+	keys, err := db.Enumerate("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pairs) != len(keys) {
+		t.Fatal("expected", len(keys), "pairs, got:", len(pairs))
+	}
+
+	expected := map[string]string{key: val, otherKey: otherVal, "/a/b/d": "d"}
+	for i, p := range pairs {
+		if p.Key != keys[i] {
+			t.Fatal("expected key", keys[i], "at", i, "got:", p.Key)
+		}
+
+		if string(p.Value) != expected[p.Key] {
+			t.Fatal("expected", expected[p.Key], "for", p.Key, "got:", string(p.Value))
+		}
+	}
+}
+
+func testEnumerateValuesLeaf(t *testing.T, db kv.KV) {
+	e := valueEnumerator(t, db)
+	setKeys(t, db)
+
+	_, err := e.EnumerateValues(key)
+	expectErr(t, err, kv.ErrPathIsValue)
+
+	_, err = e.EnumerateValues("/x")
+	expectErr(t, err, kv.ErrNotFound)
+}
+
+// lister skips the test unless db implements kv.Lister.
+func lister(t *testing.T, db kv.KV) kv.Lister {
+	t.Helper()
+
+	l, ok := db.(kv.Lister)
+	if !ok {
+		t.Skip("kv.Lister not implemented")
+	}
+
+	return l
+}
+
+func testList(t *testing.T, db kv.KV) {
+	l := lister(t, db)
+	setKeys(t, db)
+
+	for _, k := range []string{"/a/b/d/x", "/a/e"} {
+		if err := db.Set(k, []byte(val)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := l.List("/a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []kv.Entry{
+		{Key: "/a/b/c", Name: "c", IsBucket: true, Children: 2},
+		{Key: "/a/b/d", Name: "d", IsBucket: true, Children: 1},
+	}
+	expectEntries(t, entries, expected...)
+
+	entries, err = l.List("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []kv.Entry{
+		{Key: "/a/b", Name: "b", IsBucket: true, Children: 2},
+		{Key: "/a/e", Name: "e"},
+	}
+	expectEntries(t, entries, expected...)
+
+	entries, err = l.List("/a/b/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []kv.Entry{
+		{Key: key, Name: "myKey"},
+		{Key: otherKey, Name: "someOtherKey"},
+	}
+	expectEntries(t, entries, expected...)
+}
+
+func testListLeaf(t *testing.T, db kv.KV) {
+	l := lister(t, db)
+	setKeys(t, db)
+
+	_, err := l.List(key)
+	expectErr(t, err, kv.ErrPathIsValue)
+
+	_, err = l.List("/a/d")
+	expectErr(t, err, kv.ErrNotFound)
+}
+
+// expectEntries fails the test unless entries match expected in order.
+func expectEntries(t *testing.T, entries []kv.Entry, expected ...kv.Entry) {
+	t.Helper()
+
+	if len(entries) != len(expected) {
+		t.Fatal("expected", expected, "got:", entries)
+	}
+
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.Fatal("expected", expected[i], "at", i, "got:", entries[i])
+		}
+	}
+}
+
+// stater skips the test unless db implements kv.Stater.
+func stater(t *testing.T, db kv.KV) kv.Stater {
+	t.Helper()
+
+	s, ok := db.(kv.Stater)
+	if !ok {
+		t.Skip("kv.Stater not implemented")
+	}
+
+	return s
+}
+
+func testStat(t *testing.T, db kv.KV) {
+	s := stater(t, db)
+	setKeys(t, db)
+
+	info, err := s.Stat(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info != (kv.Info{Key: key, Exists: true, Size: len(val)}) {
+		t.Fatal("unexpected info for leaf:", info)
+	}
+
+	info, err = s.Stat("/a/b/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info != (kv.Info{Key: "/a/b/c", Exists: true, IsBucket: true, Children: 2}) {
+		t.Fatal("unexpected info for bucket:", info)
+	}
+
+	if !s.Exists(key) || !s.Exists("/a/b") {
+		t.Fatal("expected keys to exist")
+	}
+}
+
+func testStatMissing(t *testing.T, db kv.KV) {
+	s := stater(t, db)
+	setKeys(t, db)
+
+	info, err := s.Stat("/a/b/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Exists {
+		t.Fatal("expected missing key not to exist")
+	}
+
+	if s.Exists("/a/b/x") || s.Exists("/x/y") {
+		t.Fatal("expected missing keys not to exist")
+	}
+
+	_, err = s.Stat(key + "/x")
+	expectErr(t, err, kv.ErrPathIsValue)
+
+	_, err = s.Stat("/")
+	expectErr(t, err, kv.ErrEmptyKey)
+}
+
+// expirer skips the test unless db implements kv.Expirer.
+func expirer(t *testing.T, db kv.KV) kv.Expirer {
+	t.Helper()
+
+	e, ok := db.(kv.Expirer)
+	if !ok {
+		t.Skip("kv.Expirer not implemented")
+	}
+
+	return e
+}
+
+func testTTL(t *testing.T, db kv.KV) {
+	e := expirer(t, db)
+	setKeys(t, db)
+
+	if err := e.SetWithTTL(key, []byte(val), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	ttl, err := e.TTL(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl <= 0 || ttl > time.Hour {
+		t.Fatal("expected ttl within an hour, got:", ttl)
+	}
+
+	// keys set without a ttl do not expire
+	if ttl, err := e.TTL(otherKey); err != nil || ttl != 0 {
+		t.Fatal("expected zero ttl, got:", ttl, err)
+	}
+
+	// setting a key again removes its expiry
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl, err := e.TTL(key); err != nil || ttl != 0 {
+		t.Fatal("expected zero ttl after set, got:", ttl, err)
+	}
+
+	expectErr(t, e.SetWithTTL(key, []byte(val), 0), kv.ErrInvalidArgument)
+	expectErr(t, e.SetWithTTL(key, []byte(val), -time.Second), kv.ErrInvalidArgument)
+
+	_, err = e.TTL("/a/b")
+	expectErr(t, err, kv.ErrIsBucket)
+
+	_, err = e.TTL("/a/b/x")
+	expectErr(t, err, kv.ErrNotFound)
+
+	_, err = e.TTL(key + "/x")
+	expectErr(t, err, kv.ErrPathIsValue)
+}
+
+func testTTLExpire(t *testing.T, db kv.KV, clock *Clock) {
+	e := expirer(t, db)
+	setKeys(t, db)
+
+	for _, k := range []string{key, "/a/x"} {
+		if err := e.SetWithTTL(k, []byte(val), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Add(time.Minute)
+
+	// expired keys are invisible
+	_, err := db.Get(key)
+	expectErr(t, err, kv.ErrNotFound)
+
+	_, err = e.TTL(key)
+	expectErr(t, err, kv.ErrNotFound)
+
+	keys, err := db.Enumerate("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, keys, otherKey)
+
+	if it, ok := db.(kv.Iterator); ok {
+		keys = nil
+		if err := it.IterateKeys(context.Background(), "/a", func(key string) error {
+			keys = append(keys, key)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		expectKeys(t, keys, otherKey)
+	}
+
+	// expired keys can be set again, even as buckets
+	if err := db.Set("/a/x/y", []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Set(key, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err = db.Enumerate("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, keys, key, otherKey, "/a/x/y")
+}
+
+func testTTLTxn(t *testing.T, db kv.KV) {
+	e := expirer(t, db)
+	setKeys(t, db)
+
+	implemented := true
+	if err := txn(t, db).Update(func(tx kv.Tx) error {
+		te, ok := tx.(kv.Expirer)
+		if !ok {
+			implemented = false
+			return nil
+		}
+
+		if err := te.SetWithTTL(key, []byte(otherVal), time.Hour); err != nil {
+			return err
+		}
+
+		// the expiry is visible within the transaction
+		ttl, err := te.TTL(key)
+		if err != nil {
+			return err
+		}
+
+		if ttl <= 0 || ttl > time.Hour {
+			return fmt.Errorf("expected ttl within an hour, got: %v", ttl)
+		}
+
+		return tx.Delete(otherKey)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !implemented {
+		t.Skip("kv.Expirer not implemented by transactions")
+	}
+
+	if ttl, err := e.TTL(key); err != nil || ttl <= 0 || ttl > time.Hour {
+		t.Fatal("expected ttl within an hour, got:", ttl, err)
+	}
+
+	_, err := db.Get(otherKey)
+	expectErr(t, err, kv.ErrNotFound)
+}
+
+// watcher skips the test unless db implements kv.Watcher.
+func watcher(t *testing.T, db kv.KV) kv.Watcher {
+	t.Helper()
+
+	w, ok := db.(kv.Watcher)
+	if !ok {
+		t.Skip("kv.Watcher not implemented")
+	}
+
+	return w
+}
+
+// expectEvents fails the test unless expected events arrive on ch in order.
+// Backends watching by polling may take a while to report changes.
+func expectEvents(t *testing.T, ch <-chan kv.Event, expected ...kv.Event) {
+	t.Helper()
+
+	timeout := time.After(30 * time.Second)
+	for _, want := range expected {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				t.Fatal("channel closed waiting for:", want)
+			}
+			if e.Kind != want.Kind || e.Key != want.Key || string(e.Value) != string(want.Value) {
+				t.Fatalf("expected event %v %s %q, got: %v %s %q",
+					want.Kind, want.Key, want.Value, e.Kind, e.Key, e.Value)
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for:", want)
+		}
+	}
+}
+
+func testWatch(t *testing.T, db kv.KV) {
+	w := watcher(t, db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// invalid prefixes are rejected rather than watching everything
+	_, err := w.Watch(ctx, "../x")
+	expectErr(t, err, kv.ErrInvalidKey)
+
+	ch, err := w.Watch(ctx, "/a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keys outside of the prefix are not reported
+	if err := db.Set("/a/x", []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	setKeys(t, db)
+	expectEvents(t, ch,
+		kv.Event{Kind: kv.EventPut, Key: key, Value: []byte(val)},
+		kv.Event{Kind: kv.EventPut, Key: otherKey, Value: []byte(otherVal)},
+	)
+
+	// deleting a bucket reports every key in it
+	if err := db.Delete("/a/b/c"); err != nil {
+		t.Fatal(err)
+	}
+
+	expectEvents(t, ch,
+		kv.Event{Kind: kv.EventDelete, Key: key},
+		kv.Event{Kind: kv.EventDelete, Key: otherKey},
+	)
+}
+
+func testWatchKey(t *testing.T, db kv.KV) {
+	w := watcher(t, db)
+	setKeys(t, db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err := w.Watch(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Set(otherKey, []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+
+	// changes rolled back are not reported
+	if tx, ok := db.(kv.Txn); ok {
+		_ = tx.Update(func(tx kv.Tx) error {
+			if err := tx.Set(key, []byte("rolled back")); err != nil {
+				return err
+			}
+			return errors.New("roll back")
+		})
+	}
+
+	if err := db.Set(key, []byte(otherVal)); err != nil {
+		t.Fatal(err)
+	}
+
+	expectEvents(t, ch, kv.Event{Kind: kv.EventPut, Key: key, Value: []byte(otherVal)})
+}
+
+func testWatchCancel(t *testing.T, db kv.KV) {
+	w := watcher(t, db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := w.Watch(ctx, "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("expected no events")
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("expected channel to be closed")
+	}
+
+	if _, err := w.Watch(ctx, "/a"); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got:", err)
+	}
+}
+
+func copier(t *testing.T, db kv.KV) kv.Copier {
+	t.Helper()
+
+	c, ok := db.(kv.Copier)
+	if !ok {
+		t.Skip("kv.Copier not implemented")
+	}
+
+	return c
+}
+
+// expectValue fails the test unless key holds val.
+func expectValue(t *testing.T, db kv.KV, key, val string) {
+	t.Helper()
+
+	b, err := db.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != val {
+		t.Fatalf("expected %s at %s, got: %s", val, key, string(b))
+	}
+}
+
+func testCopy(t *testing.T, db kv.KV) {
+	c := copier(t, db)
+	setKeys(t, db)
+
+	if err := c.Copy("/a/b", "/archive/a/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := db.Enumerate("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, keys, key, otherKey, "/archive"+key, "/archive"+otherKey)
+
+	expectValue(t, db, "/archive"+key, val)
+	expectValue(t, db, "/archive"+otherKey, otherVal)
+
+	// copies are independent of each other
+	if err := db.Set(key, []byte(otherVal)); err != nil {
+		t.Fatal(err)
+	}
+	expectValue(t, db, "/archive"+key, val)
+}
+
+func testCopyLeaf(t *testing.T, db kv.KV) {
+	c := copier(t, db)
+	setKeys(t, db)
+
+	if err := c.Copy(key, "/x/copy"); err != nil {
+		t.Fatal(err)
+	}
+
+	expectValue(t, db, "/x/copy", val)
+	expectValue(t, db, key, val)
+
+	expectErr(t, c.Copy(key, "/x/copy"), kv.ErrExists)
+	expectErr(t, c.Copy(key, "/a"), kv.ErrExists)
+	expectErr(t, c.Copy("/x/missing", "/y"), kv.ErrNotFound)
+	expectErr(t, c.Copy(otherKey, key+"/z"), kv.ErrPathIsValue)
+}
+
+func testCopyTTL(t *testing.T, db kv.KV) {
+	c := copier(t, db)
+	e := expirer(t, db)
+
+	if err := e.SetWithTTL(key, []byte(val), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Copy("/a", "/x"); err != nil {
+		t.Fatal(err)
+	}
+
+	ttl, err := e.TTL("/x/b/c/myKey")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ttl <= 0 || ttl > time.Hour {
+		t.Fatal("expected copy to expire within an hour, got:", ttl)
+	}
+}
+
+func testCopyEmptyBucket(t *testing.T, db kv.KV) {
+	c := copier(t, db)
+	s := stater(t, db)
+	setKeys(t, db)
+
+	// deleting every leaf of a bucket leaves it empty.
+	for _, k := range []string{key, otherKey} {
+		if err := db.Delete(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if info, err := s.Stat("/a/b/c"); err != nil || !info.IsBucket || info.Children != 0 {
+		t.Skip("empty buckets are not kept:", info, err)
+	}
+
+	if err := c.Copy("/a/b/c", "/x/c"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Move("/a/b/c", "/y/c"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{"/x/c", "/y/c"} {
+		info, err := s.Stat(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info != (kv.Info{Key: k, Exists: true, IsBucket: true}) {
+			t.Fatal("unexpected info for copied bucket:", info)
+		}
+	}
+
+	if s.Exists("/a/b/c") {
+		t.Fatal("expected moved bucket to be gone")
+	}
+
+	if err := db.Set("/leaf", []byte(val)); err != nil {
+		t.Fatal(err)
+	}
+	expectErr(t, c.Copy("/x/c", "/leaf/c"), kv.ErrPathIsValue)
+}
+
+func testMove(t *testing.T, db kv.KV) {
+	c := copier(t, db)
+	setKeys(t, db)
+
+	if err := c.Move("/a/b", "/archive/a/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := db.Enumerate("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, keys, "/archive"+key, "/archive"+otherKey)
+	expectValue(t, db, "/archive"+otherKey, otherVal)
+
+	if err := c.Move("/archive"+key, key); err != nil {
+		t.Fatal(err)
+	}
+
+	expectValue(t, db, key, val)
+	_, err = db.Get("/archive" + key)
+	expectErr(t, err, kv.ErrNotFound)
+}
+
+func testMoveInvalid(t *testing.T, db kv.KV) {
+	c := copier(t, db)
+	setKeys(t, db)
+
+	expectErr(t, c.Move("/a/b", "/a/b/c/d"), kv.ErrInvalidKey)
+	expectErr(t, c.Move("/a/b", "/a/b"), kv.ErrInvalidKey)
+	expectErr(t, c.Move("/", "/x"), kv.ErrEmptyKey)
+	expectErr(t, c.Move(key, "/"), kv.ErrEmptyKey)
+	expectErr(t, c.Move(key, otherKey), kv.ErrExists)
+
+	// failed moves leave keys untouched
+	keys, err := db.Enumerate("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, keys, key, otherKey)
+}
+
+// StoreFactory returns a new instance of kv.Store along with a func to release
+// it once a test is done. Stores need not be empty since tests use namespaces
+// of their own.
+type StoreFactory func() (kv.Store, func())
+
+// RunStoreConformance runs the namespace tests against instances of kv.Store
+// returned by factory. Each test gets its own instance.
+func RunStoreConformance(t *testing.T, factory StoreFactory) {
+	tests := []struct {
+		name string
+		f    func(t *testing.T, s kv.Store)
+	}{
+		{"NamespaceIsolation", testNamespaceIsolation},
+		{"ListNamespaces", testListNamespaces},
+		{"DropNamespace", testDropNamespace},
+		{"RenameNamespace", testRenameNamespace},
+		{"RenameNamespaceExists", testRenameNamespaceExists},
+		{"InvalidNamespace", testInvalidNamespace},
+		{"WatchNamespace", testWatchNamespace},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			s, done := factory()
+			defer done()
+			test.f(t, s)
+		})
+	}
+}
+
+// namespaces returns n namespace names unlikely to exist in a shared store,
+// such as a datastore project, listed in sorted order.
+func namespaces(n int) []string {
+	prefix := fmt.Sprintf("kvtest-%d", time.Now().UnixNano())
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", prefix, i)
+	}
+
+	return names
+}
+
+// namespace opens ns in s and sets key and otherKey in it.
+func namespace(t *testing.T, s kv.Store, ns string) kv.KV {
+	t.Helper()
+
+	db, err := s.Namespace(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setKeys(t, db)
+
+	return db
+}
+
+// dropNamespaces drops namespaces left behind by a test.
+func dropNamespaces(s kv.Store, names ...string) {
+	for _, ns := range names {
+		_ = s.DropNamespace(ns)
+	}
+}
+
+// expectNamespaces fails the test unless listed namespaces are sorted and
+// include every namespace in included and none in excluded.
+func expectNamespaces(t *testing.T, s kv.Store, included, excluded []string) {
+	t.Helper()
+
+	names, err := s.ListNamespaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !sort.StringsAreSorted(names) {
+		t.Fatalf("expected sorted namespaces, got: %v", names)
+	}
+
+	listed := make(map[string]bool)
+	for _, ns := range names {
+		listed[ns] = true
+	}
+
+	for _, ns := range included {
+		if !listed[ns] {
+			t.Fatalf("expected namespace %s in %v", ns, names)
+		}
+	}
+
+	for _, ns := range excluded {
+		if listed[ns] {
+			t.Fatalf("unexpected namespace %s in %v", ns, names)
+		}
+	}
+}
+
+func testNamespaceIsolation(t *testing.T, s kv.Store) {
+	names := namespaces(2)
+	defer dropNamespaces(s, names...)
+
+	namespace(t, s, names[0])
+
+	db, err := s.Namespace(names[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Get(key)
+	expectErr(t, err, kv.ErrNotFound)
+
+	// a second KV over the same namespace sees the same keys
+	db, err = s.Namespace(names[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := db.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != val {
+		t.Fatalf("expected %s, got: %s", val, string(b))
+	}
+}
+
+func testListNamespaces(t *testing.T, s kv.Store) {
+	names := namespaces(3)
+	defer dropNamespaces(s, names...)
+
+	namespace(t, s, names[1])
+	namespace(t, s, names[0])
+
+	expectNamespaces(t, s, names[:2], names[2:])
+}
+
+func testDropNamespace(t *testing.T, s kv.Store) {
+	names := namespaces(2)
+	defer dropNamespaces(s, names...)
+
+	db := namespace(t, s, names[0])
+	namespace(t, s, names[1])
+
+	if err := s.DropNamespace(names[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	expectNamespaces(t, s, names[1:], names[:1])
+
+	_, err := db.Get(key)
+	expectErr(t, err, kv.ErrNotFound)
+
+	expectErr(t, s.DropNamespace(names[0]), kv.ErrNotFound)
+
+	// other namespaces are left alone
+	db, err = s.Namespace(names[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := db.Enumerate("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, keys, key, otherKey)
+}
+
+func testRenameNamespace(t *testing.T, s kv.Store) {
+	names := namespaces(2)
+	defer dropNamespaces(s, names...)
+
+	old := namespace(t, s, names[0])
+
+	if err := s.RenameNamespace(names[0], names[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	expectNamespaces(t, s, names[1:], names[:1])
+
+	_, err := old.Get(key)
+	expectErr(t, err, kv.ErrNotFound)
+
+	db, err := s.Namespace(names[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := db.Enumerate("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectKeys(t, keys, key, otherKey)
+
+	b, err := db.Get(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != otherVal {
+		t.Fatalf("expected %s, got: %s", otherVal, string(b))
+	}
+
+	expectErr(t, s.RenameNamespace(names[0], names[1]), kv.ErrNotFound)
+}
+
+func testRenameNamespaceExists(t *testing.T, s kv.Store) {
+	names := namespaces(2)
+	defer dropNamespaces(s, names...)
+
+	namespace(t, s, names[0])
+	namespace(t, s, names[1])
+
+	expectErr(t, s.RenameNamespace(names[0], names[1]), kv.ErrExists)
+	expectNamespaces(t, s, names, nil)
+}
+
+func testInvalidNamespace(t *testing.T, s kv.Store) {
+	for _, ns := range []string{"", "__kind__", "a\x00b"} {
+		_, err := s.Namespace(ns)
+		expectErr(t, err, kv.ErrInvalidNamespace)
+	}
+
+	names := namespaces(1)
+	defer dropNamespaces(s, names...)
+
+	namespace(t, s, names[0])
+	expectErr(t, s.RenameNamespace(names[0], ""), kv.ErrInvalidNamespace)
+	expectErr(t, s.RenameNamespace("", names[0]), kv.ErrInvalidNamespace)
+	expectErr(t, s.DropNamespace(""), kv.ErrInvalidNamespace)
+}
+
+func testWatchNamespace(t *testing.T, s kv.Store) {
+	names := namespaces(3)
+	defer dropNamespaces(s, names...)
+
+	for _, drop := range []func() error{
+		func() error { return s.DropNamespace(names[0]) },
+		func() error { return s.RenameNamespace(names[0], names[1]) },
+	} {
+		db := namespace(t, s, names[0])
+		w, ok := db.(kv.Watcher)
+		if !ok {
+			t.Skip("kv.Watcher not implemented")
+		}
+
+		ch, err := w.Watch(context.Background(), "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := drop(); err != nil {
+			t.Fatal(err)
+		}
+
+		// watchers of a namespace that is gone are closed rather than
+		// fed by a namespace created again under its name.
+		db = namespace(t, s, names[0])
+		timeout := time.After(30 * time.Second)
+		for closed := false; !closed; {
+			select {
+			case _, ok := <-ch:
+				closed = !ok
+			case <-timeout:
+				t.Fatal("expected watcher to be closed")
+			}
+		}
+
+		// the namespace created again can be watched.
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err = db.(kv.Watcher).Watch(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.Set(key, []byte(otherVal)); err != nil {
+			t.Fatal(err)
+		}
+
+		expectEvents(t, ch, kv.Event{Kind: kv.EventPut, Key: key, Value: []byte(otherVal)})
+		cancel()
+		dropNamespaces(s, names...)
+	}
+}
@@ -0,0 +1,34 @@
+package kv
+
+import "context"
+
+// listCursor lists the immediate children of the bucket c iterates over,
+// counting the children of each child bucket along the way. Keys of entries
+// are joined onto prefix.
+func listCursor(ctx context.Context, c cursor, prefix string) ([]Entry, error) {
+	var entries []Entry
+	for name, leaf := c.First(); name != ""; name, leaf = c.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		e := Entry{Key: joinKey(prefix, escapeSegment(name)), Name: name, IsBucket: !leaf}
+		if e.IsBucket {
+			e.Children = countChildren(c.Bucket())
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// countChildren counts the children of the bucket c iterates over.
+func countChildren(c cursor) int {
+	n := 0
+	for name, _ := c.First(); name != ""; name, _ = c.Next() {
+		n++
+	}
+
+	return n
+}
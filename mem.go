@@ -1,54 +1,709 @@
 package kv
 
 import (
-	"fmt"
-	"path/filepath"
-	"strings"
+	"context"
+	"sort"
 	"sync"
+	"time"
 )
 
+var (
+	_ KVContext       = (*memdb)(nil)
+	_ Txn             = (*memdb)(nil)
+	_ Versioned       = (*memdb)(nil)
+	_ Conditional     = (*memdb)(nil)
+	_ Iterator        = (*memdb)(nil)
+	_ Pager           = (*memdb)(nil)
+	_ Scanner         = (*memdb)(nil)
+	_ ValueEnumerator = (*memdb)(nil)
+	_ Lister          = (*memdb)(nil)
+	_ Stater          = (*memdb)(nil)
+	_ Expirer         = (*memdb)(nil)
+	_ Watcher         = (*memdb)(nil)
+	_ Copier          = (*memdb)(nil)
+	_ Expirer         = (*memTx)(nil)
+	_ revisionTx      = (*memTx)(nil)
+)
+
+var _ Store = (*memStore)(nil)
+
+// memStore holds the trees of all namespaces of an in-memory database.
+type memStore struct {
+	mu sync.RWMutex
+	// links maps namespaces to the roots of their trees.
+	links    map[string]*node
+	hubs     map[string]*hub
+	now      func() time.Time
+	interval time.Duration
+	ticker   func(d time.Duration) (<-chan time.Time, func())
+	// sweeping is set while the janitor is running.
+	sweeping bool
+	// rev is the last revision a leaf was written at in any namespace.
+	rev uint64
+}
+
+// memdb implements KV over a namespace of a memStore.
 type memdb struct {
-	mu        sync.Mutex
+	*memStore
 	nameSpace string
-	links     map[string]*node
 }
 
 type node struct {
 	value []byte
 	links map[string]*node
+	// expires is the time a leaf expires at, or zero if it does not expire.
+	expires time.Time
+	// rev is the revision a leaf was last written at.
+	rev uint64
+}
+
+// expired reports whether n is a leaf that has expired at now.
+func (n *node) expired(now time.Time) bool {
+	return n.value != nil && expired(n.expires, now)
+}
+
+// memTx implements Tx over the tree of a memdb. A copy-on-write transaction
+// clones every node along the paths it modifies, so the tree it started from
+// is left untouched until the new root is committed.
+type memTx struct {
+	ctx  context.Context
+	root *node
+	// now is the time at which the transaction started, which decides
+	// which keys have expired.
+	now      time.Time
+	writable bool
+	cow      bool
+	// owned holds nodes cloned or created by a copy-on-write transaction,
+	// which can therefore be modified in place.
+	owned map[*node]bool
+	// events are recorded if watch is set and published on commit.
+	watch  bool
+	events []Event
+	// expiring is set once a key that expires has been set.
+	expiring bool
+	// rev points to the last revision of the store and is nil in
+	// read-only transactions.
+	rev *uint64
 }
 
 // newMemKv provides a new instance of KV
-func newMemKv() *memdb {
-	m := new(memdb)
-	m.nameSpace = "default"
-	m.links = make(map[string]*node)
-	n := new(node)
-	n.links = make(map[string]*node)
-	m.links[m.nameSpace] = n
+func newMemKv(opts ...Option) *memdb {
+	m, _ := newMemStore(opts...).namespace("default")
 	return m
 }
 
-func (m *memdb) Get(key string) ([]byte, error) {
-	n, ok := m.links[m.nameSpace]
+// newMemStore provides a new in-memory database without namespaces.
+func newMemStore(opts ...Option) *memStore {
+	o := newOptions(opts)
+	return &memStore{
+		links:    make(map[string]*node),
+		hubs:     make(map[string]*hub),
+		now:      o.now,
+		interval: o.janitorInterval,
+		ticker:   o.ticker,
+	}
+}
+
+// Namespace returns a KV over namespace ns, creating it if it does not exist.
+func (s *memStore) Namespace(ns string) (KV, error) {
+	return s.namespace(ns)
+}
+
+func (s *memStore) namespace(ns string) (*memdb, error) {
+	if err := checkNamespace(ns); err != nil {
+		return nil, namespaceError("open", ns, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[ns]; !ok {
+		s.links[ns] = &node{links: make(map[string]*node)}
+	}
+
+	return &memdb{memStore: s, nameSpace: ns}, nil
+}
+
+// hub returns watchers of namespace ns, which are shared by all memdbs
+// over it. s.mu must be held for writing.
+func (s *memStore) hub(ns string) *hub {
+	h, ok := s.hubs[ns]
 	if !ok {
-		return nil, fmt.Errorf("namespace not found")
+		h = newHub()
+		s.hubs[ns] = h
 	}
 
-	for _, key := range splitKey(key, m.nameSpace) {
-		if n.links == nil {
-			return nil, fmt.Errorf("invalid key, key not found")
+	return h
+}
+
+// closeHub ends watchers of namespace ns once it is dropped or renamed, so
+// that they are not fed by a namespace created again under its name. s.mu
+// must be held for writing.
+func (s *memStore) closeHub(ns string) {
+	if h, ok := s.hubs[ns]; ok {
+		h.close()
+		delete(s.hubs, ns)
+	}
+}
+
+// ListNamespaces lists namespaces in sorted order.
+func (s *memStore) ListNamespaces() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.links))
+	for ns := range s.links {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// DropNamespace deletes a namespace along with all keys in it. KVs over
+// it fail with ErrNotFound until it is created again, and their watchers
+// are closed.
+func (s *memStore) DropNamespace(ns string) error {
+	if err := checkNamespace(ns); err != nil {
+		return namespaceError("drop", ns, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.links[ns]; !ok {
+		return namespaceError("drop", ns, ErrNotFound)
+	}
+
+	delete(s.links, ns)
+	s.closeHub(ns)
+	return nil
+}
+
+// RenameNamespace renames a namespace. KVs over the old name fail with
+// ErrNotFound until it is created again, and their watchers are closed.
+func (s *memStore) RenameNamespace(old, new string) error {
+	if err := checkNamespace(old); err != nil {
+		return namespaceError("rename", old, err)
+	}
+
+	if err := checkNamespace(new); err != nil {
+		return namespaceError("rename", new, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	root, ok := s.links[old]
+	if !ok {
+		return namespaceError("rename", old, ErrNotFound)
+	}
+
+	if _, ok := s.links[new]; ok {
+		return namespaceError("rename", new, ErrExists)
+	}
+
+	s.links[new] = root
+	delete(s.links, old)
+	s.closeHub(old)
+	return nil
+}
+
+// view runs f in a read-only transaction.
+func (m *memdb) view(ctx context.Context, f func(tx *memTx) error) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	root, ok := m.links[m.nameSpace]
+	if !ok {
+		return ErrNotFound
+	}
+
+	return f(&memTx{ctx: ctx, root: root, now: m.now()})
+}
+
+// update runs f in a read-write transaction holding the write lock.
+// Single operations that leave the tree untouched when they fail can
+// skip the copy-on-write overhead by passing cow as false.
+func (m *memdb) update(ctx context.Context, cow bool, f func(tx *memTx) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	root, ok := m.links[m.nameSpace]
+	if !ok {
+		return ErrNotFound
+	}
+
+	tx := &memTx{ctx: ctx, root: root, now: m.now(), writable: true, cow: cow, watch: m.hub(m.nameSpace).active(), rev: &m.rev}
+	if cow {
+		tx.owned = make(map[*node]bool)
+	}
+
+	if err := f(tx); err != nil {
+		return err
+	}
+
+	m.links[m.nameSpace] = tx.root
+	m.hub(m.nameSpace).publish(tx.events)
+
+	// make sure the janitor is running to reclaim expiring keys.
+	if tx.expiring && !m.sweeping {
+		m.sweeping = true
+		go m.janitor()
+	}
+
+	return nil
+}
+
+// Update runs f in a copy-on-write transaction.
+func (m *memdb) Update(f func(tx Tx) error) error {
+	return m.update(context.Background(), true, func(tx *memTx) error { return f(tx) })
+}
+
+// View runs f in a read-only transaction.
+func (m *memdb) View(f func(tx Tx) error) error {
+	return m.view(context.Background(), func(tx *memTx) error { return f(tx) })
+}
+
+func (m *memdb) Get(key string) ([]byte, error) {
+	return m.GetContext(context.Background(), key)
+}
+
+func (m *memdb) GetContext(ctx context.Context, key string) ([]byte, error) {
+	var val []byte
+	err := m.view(ctx, func(tx *memTx) error {
+		var err error
+		val, err = tx.Get(key)
+		return err
+	})
+
+	return val, keyError("get", key, err)
+}
+
+func (m *memdb) Set(key string, val []byte) error {
+	return m.SetContext(context.Background(), key, val)
+}
+
+func (m *memdb) SetContext(ctx context.Context, key string, val []byte) error {
+	return keyError("set", key, m.update(ctx, false, func(tx *memTx) error {
+		return tx.Set(key, val)
+	}))
+}
+
+func (m *memdb) Delete(key string) error {
+	return m.DeleteContext(context.Background(), key)
+}
+
+func (m *memdb) DeleteContext(ctx context.Context, key string) error {
+	return keyError("delete", key, m.update(ctx, false, func(tx *memTx) error {
+		return tx.Delete(key)
+	}))
+}
+
+func (m *memdb) Enumerate(key string) ([]string, error) {
+	return m.EnumerateContext(context.Background(), key)
+}
+
+func (m *memdb) EnumerateContext(ctx context.Context, key string) ([]string, error) {
+	var keys []string
+	err := m.view(ctx, func(tx *memTx) error {
+		var err error
+		keys, err = tx.Enumerate(key)
+		return err
+	})
+
+	return keys, keyError("enumerate", key, err)
+}
+
+// EnumerateValues lists all keys under a key along with their values
+// in a single walk.
+func (m *memdb) EnumerateValues(key string) ([]Pair, error) {
+	s := &scanCollector{values: true}
+	if err := m.view(context.Background(), func(tx *memTx) error {
+		return tx.walk(key, s.add)
+	}); err != nil {
+		return nil, keyError("enumerate", key, err)
+	}
+
+	return s.pairs, nil
+}
+
+// List lists the immediate children of a bucket.
+func (m *memdb) List(prefix string) ([]Entry, error) {
+	var entries []Entry
+	if err := m.view(context.Background(), func(tx *memTx) error {
+		n, err := tx.bucket(prefix)
+		if err != nil {
+			return err
+		}
+
+		entries, err = listCursor(tx.ctx, newMemCursor(n, tx.now), prefix)
+		return err
+	}); err != nil {
+		return nil, keyError("list", prefix, err)
+	}
+
+	return entries, nil
+}
+
+// Stat describes a key.
+func (m *memdb) Stat(key string) (Info, error) {
+	var info Info
+	err := m.view(context.Background(), func(tx *memTx) error {
+		keys, err := splitKey(key)
+		if err != nil {
+			return err
+		}
+
+		if len(keys) == 0 {
+			return ErrEmptyKey
+		}
+
+		n, err := tx.lookup(keys)
+		if err != nil {
+			return err
+		}
+
+		if n.value != nil {
+			info.Size = len(n.value)
+		} else {
+			info.IsBucket = true
+			info.Children = countChildren(newMemCursor(n, tx.now))
+		}
+
+		return nil
+	})
+
+	return statResult(key, info, err)
+}
+
+// Exists reports whether a key exists.
+func (m *memdb) Exists(key string) bool {
+	info, err := m.Stat(key)
+	return err == nil && info.Exists
+}
+
+// SetWithTTL sets a value that expires after ttl, which is reclaimed by
+// the janitor.
+func (m *memdb) SetWithTTL(key string, val []byte, ttl time.Duration) error {
+	return keyError("set", key, m.update(context.Background(), false, func(tx *memTx) error {
+		return tx.SetWithTTL(key, val, ttl)
+	}))
+}
+
+// TTL returns the time left before a key expires.
+func (m *memdb) TTL(key string) (time.Duration, error) {
+	var ttl time.Duration
+	err := m.view(context.Background(), func(tx *memTx) error {
+		var err error
+		ttl, err = tx.TTL(key)
+		return err
+	})
+
+	return ttl, keyError("ttl", key, err)
+}
+
+// janitor reclaims expired keys every interval. It exits once no keys are
+// left to expire, so a memdb without expiring keys runs no goroutine.
+func (m *memStore) janitor() {
+	ticks, stop := m.ticker(m.interval)
+	defer stop()
+
+	for range ticks {
+		if !m.sweep() {
+			return
 		}
+	}
+}
+
+// sweep removes expired leaves from every namespace and reports whether
+// any leaves are left to expire, clearing sweeping otherwise. Nodes are
+// modified in place since no transaction can be running while the write
+// lock is held.
+func (m *memStore) sweep() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	left := 0
+	for _, root := range m.links {
+		left += sweepNode(root, now)
+	}
+
+	m.sweeping = left > 0
+	return m.sweeping
+}
+
+// sweepNode removes expired leaves below n and returns the number of
+// leaves below n left to expire.
+func sweepNode(n *node, now time.Time) int {
+	left := 0
+	for name, l := range n.links {
+		switch {
+		case l.value == nil:
+			left += sweepNode(l, now)
+		case l.expired(now):
+			delete(n.links, name)
+		case !l.expires.IsZero():
+			left++
+		}
+	}
+
+	return left
+}
+
+// Watch reports changes to keys under prefix made through this memdb.
+func (m *memdb) Watch(ctx context.Context, prefix string) (<-chan Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, keyError("watch", prefix, err)
+	}
+
+	// holding the write lock makes sure no transaction is under way
+	// that started without recording events.
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys, err := splitKey(prefix)
+	if err != nil {
+		return nil, keyError("watch", prefix, err)
+	}
+
+	if _, ok := m.links[m.nameSpace]; !ok {
+		return nil, keyError("watch", prefix, ErrNotFound)
+	}
+
+	return m.hub(m.nameSpace).watch(ctx, keys), nil
+}
+
+// GetWithVersion gets a value along with its version.
+func (m *memdb) GetWithVersion(key string) ([]byte, Version, error) {
+	var val []byte
+	var version Version
+	err := m.view(context.Background(), func(tx *memTx) error {
+		var err error
+		val, version, err = getWithVersion(tx, key)
+		return err
+	})
+
+	return val, version, keyError("get", key, err)
+}
+
+// SetIfVersion sets a value if key is still at version.
+func (m *memdb) SetIfVersion(key string, val []byte, version Version) error {
+	return keyError("set", key, m.update(context.Background(), false, func(tx *memTx) error {
+		return setIfVersion(tx, key, val, version)
+	}))
+}
+
+// DeleteIfVersion deletes a key if it is still at version.
+func (m *memdb) DeleteIfVersion(key string, version Version) error {
+	return keyError("delete", key, m.update(context.Background(), false, func(tx *memTx) error {
+		return deleteIfVersion(tx, key, version)
+	}))
+}
+
+// SetIfAbsent sets a value if key does not exist.
+func (m *memdb) SetIfAbsent(key string, val []byte) error {
+	return keyError("set", key, m.update(context.Background(), false, func(tx *memTx) error {
+		return setIfAbsent(tx, key, val)
+	}))
+}
+
+// Replace sets a value if key exists.
+func (m *memdb) Replace(key string, val []byte) error {
+	return keyError("set", key, m.update(context.Background(), false, func(tx *memTx) error {
+		return replace(tx, key, val)
+	}))
+}
 
+// Copy copies the leaf or bucket at src to dst while holding the write lock.
+func (m *memdb) Copy(src, dst string) error {
+	return keyError("copy", src, m.update(context.Background(), true, func(tx *memTx) error {
+		return copyKeys(tx, src, dst, false)
+	}))
+}
+
+// Move moves the leaf or bucket at src to dst while holding the write lock.
+func (m *memdb) Move(src, dst string) error {
+	return keyError("move", src, m.update(context.Background(), true, func(tx *memTx) error {
+		return copyKeys(tx, src, dst, true)
+	}))
+}
+
+// Iterate calls f for every key under prefix along with a copy of its value
+// while holding the read lock.
+func (m *memdb) Iterate(ctx context.Context, prefix string, f func(key string, val []byte) error) error {
+	return keyError("iterate", prefix, m.view(ctx, func(tx *memTx) error {
+		return tx.walk(prefix, copyValues(f))
+	}))
+}
+
+// IterateKeys calls f for every key under prefix while holding the read lock.
+func (m *memdb) IterateKeys(ctx context.Context, prefix string, f func(key string) error) error {
+	return keyError("iterate", prefix, m.view(ctx, func(tx *memTx) error {
+		return tx.walk(prefix, func(key string, _ []byte) error { return f(key) })
+	}))
+}
+
+// EnumeratePage lists a page of keys under prefix in sorted order.
+func (m *memdb) EnumeratePage(prefix string, pageSize int, token string) ([]string, string, error) {
+	if err := checkPageSize(pageSize); err != nil {
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	after, err := parsePageToken(token)
+	if err != nil {
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	p := &pageCollector{prefix: prefix, after: after, pageSize: pageSize}
+	if err := m.view(context.Background(), func(tx *memTx) error {
+		n, err := tx.bucket(prefix)
+		if err != nil {
+			return err
+		}
+
+		return scanRange(tx.ctx, newMemCursor(n, tx.now), "", splitPath(after), nil, false, p.add)
+	}); err != nil && err != errStop {
+		return nil, "", keyError("enumerate", prefix, err)
+	}
+
+	keys, next := p.page()
+	return keys, next, nil
+}
+
+// Scan lists pairs under prefix within a range in sorted order.
+func (m *memdb) Scan(prefix, start, end string, reverse bool, limit int) ([]Pair, error) {
+	s := &scanCollector{prefix: prefix, limit: limit, values: true}
+	if err := m.scan(prefix, start, end, reverse, s); err != nil {
+		return nil, err
+	}
+
+	return s.pairs, nil
+}
+
+// ScanKeys lists keys under prefix within a range in sorted order.
+func (m *memdb) ScanKeys(prefix, start, end string, reverse bool, limit int) ([]string, error) {
+	s := &scanCollector{prefix: prefix, limit: limit}
+	if err := m.scan(prefix, start, end, reverse, s); err != nil {
+		return nil, err
+	}
+
+	return s.keys(), nil
+}
+
+func (m *memdb) scan(prefix, start, end string, reverse bool, s *scanCollector) error {
+	startKeys, endKeys, err := scanBounds(start, end)
+	if err != nil {
+		return keyError("scan", prefix, err)
+	}
+
+	if err := m.view(context.Background(), func(tx *memTx) error {
+		n, err := tx.bucket(prefix)
+		if err != nil {
+			return err
+		}
+
+		return scanRange(tx.ctx, newMemCursor(n, tx.now), "", startKeys, endKeys, reverse, s.add)
+	}); err != nil && err != errStop {
+		return keyError("scan", prefix, err)
+	}
+
+	return nil
+}
+
+// lookup walks the tree along keys and returns the node they point to.
+func (tx *memTx) lookup(keys []string) (*node, error) {
+	n := tx.root
+	for _, key := range keys {
+		if n.value != nil {
+			return nil, ErrPathIsValue
+		}
+
+		var ok bool
 		n, ok = n.links[key]
-		if !ok {
-			return nil, fmt.Errorf("invalid key, key not found")
+		if !ok || n.expired(tx.now) {
+			return nil, ErrNotFound
+		}
+	}
+
+	return n, nil
+}
+
+// expiry returns the time the leaf at key expires at, or zero if it does not expire.
+func (tx *memTx) expiry(key string) time.Time {
+	keys, err := splitKey(key)
+	if err != nil {
+		return time.Time{}
+	}
+
+	n, err := tx.lookup(keys)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return n.expires
+}
+
+// own returns n if it can be modified in place, or a clone of it otherwise.
+func (tx *memTx) own(n *node) *node {
+	if !tx.cow || tx.owned[n] {
+		return n
+	}
+
+	c := &node{value: n.value, links: make(map[string]*node, len(n.links)), expires: n.expires, rev: n.rev}
+	for k, v := range n.links {
+		c.links[k] = v
+	}
+	tx.owned[c] = true
+
+	return c
+}
+
+// mutable returns the node keys point to after making every node along
+// the path modifiable, creating missing ones and replacing expired ones.
+func (tx *memTx) mutable(keys []string) *node {
+	tx.root = tx.own(tx.root)
+
+	n := tx.root
+	for _, key := range keys {
+		child, ok := n.links[key]
+		if !ok || child.expired(tx.now) {
+			child = &node{links: make(map[string]*node)}
+			if tx.cow {
+				tx.owned[child] = true
+			}
+		} else {
+			child = tx.own(child)
 		}
+		n.links[key] = child
+		n = child
 	}
 
-	if len(n.links) != 0 || n.value == nil {
-		return nil, fmt.Errorf("invalid key, key does not refer to leaf node:%d, %d",
-			len(n.links), len(n.value))
+	return n
+}
+
+func (tx *memTx) Get(key string) ([]byte, error) {
+	if err := tx.ctx.Err(); err != nil {
+		return nil, keyError("get", key, err)
+	}
+
+	keys, err := splitKey(key)
+	if err != nil {
+		return nil, keyError("get", key, err)
+	}
+
+	if len(keys) == 0 {
+		return nil, keyError("get", key, ErrEmptyKey)
+	}
+
+	n, err := tx.lookup(keys)
+	if err != nil {
+		return nil, keyError("get", key, err)
+	}
+
+	if n.value == nil {
+		return nil, keyError("get", key, ErrIsBucket)
 	}
 
 	b := make([]byte, len(n.value))
@@ -59,120 +714,293 @@ func (m *memdb) Get(key string) ([]byte, error) {
 	return b, nil
 }
 
-func (m *memdb) Set(key string, val []byte) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// getRevision gets a value along with the version of its revision.
+func (tx *memTx) getRevision(key string) ([]byte, Version, error) {
+	val, err := tx.Get(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// key parses and points to a leaf since Get succeeded.
+	keys, _ := splitKey(key)
+	n, _ := tx.lookup(keys)
+	return val, revisionVersion(n.rev), nil
+}
+
+func (tx *memTx) Set(key string, val []byte) error {
+	return tx.set(key, val, time.Time{})
+}
 
-	if len(key) == 0 {
-		return fmt.Errorf("cannot set empty key")
+// set sets a value that expires at expires, or never if expires is zero.
+func (tx *memTx) set(key string, val []byte, expires time.Time) error {
+	if err := tx.ctx.Err(); err != nil {
+		return keyError("set", key, err)
 	}
 
-	if val == nil {
-		return fmt.Errorf("cannot set nil value, use zero value please")
+	if !tx.writable {
+		return keyError("set", key, ErrReadOnly)
 	}
 
-	n, ok := m.links[m.nameSpace]
-	if !ok {
-		return fmt.Errorf("namespace not found")
+	keys, err := splitKey(key)
+	if err != nil {
+		return keyError("set", key, err)
+	}
+
+	if len(keys) == 0 {
+		return keyError("set", key, ErrEmptyKey)
+	}
+
+	if val == nil {
+		return keyError("set", key, ErrNilValue)
 	}
 
-	for _, key := range splitKey(key, m.nameSpace) {
-		if n.links == nil {
-			n.links = make(map[string]*node)
+	// check the path before modifying anything so that a failing
+	// Set leaves the tree untouched.
+	n := tx.root
+	for _, k := range keys {
+		if n.value != nil {
+			return keyError("set", key, ErrPathIsValue)
 		}
 
-		if m, ok := n.links[key]; !ok {
-			m = new(node)
-			m.links = make(map[string]*node)
-			n.links[key] = m
-			n = m
-		} else {
-			n = m
+		var ok bool
+		if n, ok = n.links[k]; !ok || n.expired(tx.now) {
+			n = nil
+			break
 		}
 	}
 
-	if len(n.links) != 0 {
-		return fmt.Errorf("invalid key, key already exists and points to a bucket, not a value")
+	if n != nil && n.value == nil && len(n.links) != 0 {
+		return keyError("set", key, ErrIsBucket)
 	}
 
 	b := make([]byte, len(val))
 	for i := range val {
 		b[i] = val[i]
 	}
+	n = tx.mutable(keys)
 	n.value = b
+	n.expires = expires
+	*tx.rev++
+	n.rev = *tx.rev
+	if !expires.IsZero() {
+		tx.expiring = true
+	}
+
+	if tx.watch {
+		tx.events = append(tx.events, Event{Kind: EventPut, Key: Key(keys).String(), Value: b})
+	}
 
 	return nil
 }
 
-func (m *memdb) Delete(key string) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// createBucket creates a bucket at key along with buckets along the path,
+// leaving an existing bucket as it is.
+func (tx *memTx) createBucket(key string) error {
+	if err := tx.ctx.Err(); err != nil {
+		return keyError("create", key, err)
+	}
 
-	n, ok := m.links[m.nameSpace]
-	if !ok {
-		return fmt.Errorf("namespace not found")
+	if !tx.writable {
+		return keyError("create", key, ErrReadOnly)
 	}
 
-	parent := n
-	keyToDelete := key
+	keys, err := splitKey(key)
+	if err != nil {
+		return keyError("create", key, err)
+	}
 
-	for _, key := range splitKey(key, m.nameSpace) {
-		if n.links == nil {
-			return fmt.Errorf("invalid key, key not found")
-		}
+	if len(keys) == 0 {
+		return keyError("create", key, ErrEmptyKey)
+	}
 
-		parent = n
-		keyToDelete = key
+	n := tx.root
+	for _, k := range keys {
+		var ok bool
+		if n, ok = n.links[k]; !ok || n.expired(tx.now) {
+			break
+		}
 
-		n, ok = n.links[key]
-		if !ok {
-			return fmt.Errorf("invalid key, key not found")
+		if n.value != nil {
+			return keyError("create", key, ErrPathIsValue)
 		}
 	}
 
-	delete(parent.links, keyToDelete)
+	tx.mutable(keys)
 
 	return nil
 }
 
-func (m *memdb) Enumerate(key string) ([]string, error) {
-	n, ok := m.links[m.nameSpace]
-	if !ok {
-		return nil, fmt.Errorf("namespace not found")
+// SetWithTTL sets a value that expires after ttl.
+func (tx *memTx) SetWithTTL(key string, val []byte, ttl time.Duration) error {
+	if err := checkTTL(ttl); err != nil {
+		return keyError("set", key, err)
 	}
 
-	for _, key := range splitKey(key, m.nameSpace) {
-		if n.links == nil {
-			return nil, fmt.Errorf("invalid key, key not found")
-		}
+	return tx.set(key, val, tx.now.Add(ttl))
+}
 
-		n, ok = n.links[key]
-		if !ok {
-			return nil, fmt.Errorf("invalid key, key not found")
-		}
+// TTL returns the time left before a key expires.
+func (tx *memTx) TTL(key string) (time.Duration, error) {
+	keys, err := splitKey(key)
+	if err != nil {
+		return 0, keyError("ttl", key, err)
 	}
 
-	keys := make([]string, 0, len(n.links))
-	for k, v := range n.links {
-		if len(v.links) > 0 {
-			subKeys, err := m.Enumerate(filepath.Join(key, k))
-			if err != nil {
-				return nil, err
-			}
-			keys = append(keys, subKeys...)
-		} else {
-			if v.value != nil {
-				keys = append(keys, filepath.Join(key, k))
-			}
+	if len(keys) == 0 {
+		return 0, keyError("ttl", key, ErrEmptyKey)
+	}
+
+	n, err := tx.lookup(keys)
+	if err != nil {
+		return 0, keyError("ttl", key, err)
+	}
+
+	if n.value == nil {
+		return 0, keyError("ttl", key, ErrIsBucket)
+	}
+
+	return timeLeft(n.expires, tx.now), nil
+}
+
+func (tx *memTx) Delete(key string) error {
+	if err := tx.ctx.Err(); err != nil {
+		return keyError("delete", key, err)
+	}
+
+	if !tx.writable {
+		return keyError("delete", key, ErrReadOnly)
+	}
+
+	keys, err := splitKey(key)
+	if err != nil {
+		return keyError("delete", key, err)
+	}
+
+	if len(keys) == 0 {
+		return keyError("delete", key, ErrEmptyKey)
+	}
+
+	n, err := tx.lookup(keys)
+	if err != nil {
+		return keyError("delete", key, err)
+	}
+
+	if tx.watch {
+		if err := tx.recordDelete(n, keys); err != nil {
+			return keyError("delete", key, err)
 		}
 	}
 
+	parent := tx.mutable(keys[:len(keys)-1])
+	delete(parent.links, keys[len(keys)-1])
+
+	return nil
+}
+
+func (tx *memTx) Enumerate(key string) ([]string, error) {
+	var keys []string
+	if err := tx.walk(key, func(key string, _ []byte) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		return nil, keyError("enumerate", key, err)
+	}
+
 	return keys, nil
 }
 
-func splitKey(key, nameSpace string) []string {
-	key = filepath.Join(nameSpace, key)
-	keys := strings.Split(key, "/")
-	keys = keys[1:]
-	return keys
+// recordDelete records a delete event for every leaf n holds, or for n
+// itself if it is a leaf.
+func (tx *memTx) recordDelete(n *node, keys []string) error {
+	if n.value != nil {
+		tx.events = append(tx.events, Event{Kind: EventDelete, Key: Key(keys).String()})
+		return nil
+	}
+
+	return scanRange(tx.ctx, newMemCursor(n, tx.now), Key(keys).String(), nil, nil, false,
+		func(key string, _ []byte) error {
+			tx.events = append(tx.events, Event{Kind: EventDelete, Key: key})
+			return nil
+		})
+}
+
+// bucket returns the node of the bucket key points to.
+func (tx *memTx) bucket(key string) (*node, error) {
+	if err := tx.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	keys, err := splitKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := tx.lookup(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.value != nil {
+		return nil, ErrPathIsValue
+	}
+
+	return n, nil
+}
+
+// walk calls f for every leaf under the bucket key points to in sorted order.
+func (tx *memTx) walk(key string, f func(key string, val []byte) error) error {
+	n, err := tx.bucket(key)
+	if err != nil {
+		return err
+	}
+
+	return scanRange(tx.ctx, newMemCursor(n, tx.now), key, nil, nil, false, f)
+}
+
+// memCursor implements cursor over the children of a node, skipping
+// leaves that have expired at now.
+type memCursor struct {
+	n     *node
+	now   time.Time
+	names []string
+	i     int
+}
+
+func newMemCursor(n *node, now time.Time) *memCursor {
+	names := make([]string, 0, len(n.links))
+	for k, l := range n.links {
+		if !l.expired(now) {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	return &memCursor{n: n, now: now, names: names}
+}
+
+// at moves the cursor to the child at index i.
+func (c *memCursor) at(i int) (string, bool) {
+	c.i = i
+	if i < 0 || i >= len(c.names) {
+		return "", false
+	}
+
+	return c.names[i], c.n.links[c.names[i]].value != nil
+}
+
+func (c *memCursor) First() (string, bool) { return c.at(0) }
+func (c *memCursor) Last() (string, bool)  { return c.at(len(c.names) - 1) }
+func (c *memCursor) Next() (string, bool)  { return c.at(c.i + 1) }
+func (c *memCursor) Prev() (string, bool)  { return c.at(c.i - 1) }
+
+func (c *memCursor) Seek(name string) (string, bool) {
+	return c.at(sort.SearchStrings(c.names, name))
+}
+
+func (c *memCursor) Value() []byte {
+	return c.n.links[c.names[c.i]].value
+}
+
+func (c *memCursor) Bucket() cursor {
+	return newMemCursor(c.n.links[c.names[c.i]], c.now)
 }
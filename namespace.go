@@ -0,0 +1,26 @@
+package kv
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// checkNamespace returns ErrInvalidNamespace unless ns can name a namespace.
+func checkNamespace(ns string) error {
+	if ns == "" {
+		return fmt.Errorf("%w: empty name", ErrInvalidNamespace)
+	}
+
+	if strings.HasPrefix(ns, "__") {
+		return fmt.Errorf("%w: reserved name", ErrInvalidNamespace)
+	}
+
+	for _, r := range ns {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%w: control character %q", ErrInvalidNamespace, r)
+		}
+	}
+
+	return nil
+}
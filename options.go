@@ -0,0 +1,67 @@
+package kv
+
+import "time"
+
+const (
+	// defaultJanitorInterval is how often expired keys are reclaimed by default.
+	defaultJanitorInterval = time.Minute
+	// defaultPollInterval is how often changes are polled for by default.
+	defaultPollInterval = 5 * time.Second
+)
+
+// Option configures a backend returned by one of the constructors.
+type Option func(*options)
+
+type options struct {
+	now             func() time.Time
+	janitorInterval time.Duration
+	pollInterval    time.Duration
+	// ticker returns a channel ticking every d along with a func stopping it.
+	ticker func(d time.Duration) (<-chan time.Time, func())
+}
+
+// WithClock sets the func used to tell the current time when expiring keys.
+// It defaults to time.Now and is meant to be replaced in tests.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithJanitorInterval sets how often expired keys are reclaimed in the
+// background by backends that run a janitor. An interval that is not
+// positive leaves the default of a minute in place.
+func WithJanitorInterval(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.janitorInterval = d
+		}
+	}
+}
+
+// WithPollInterval sets how often backends without change notifications
+// poll for changes while watching. An interval that is not positive leaves
+// the default of five seconds in place.
+func WithPollInterval(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.pollInterval = d
+		}
+	}
+}
+
+// newTicker returns the channel of a time.Ticker along with its Stop method.
+func newTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+// newOptions applies opts over the defaults.
+func newOptions(opts []Option) *options {
+	o := &options{now: time.Now, janitorInterval: defaultJanitorInterval, pollInterval: defaultPollInterval, ticker: newTicker}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
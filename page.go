@@ -0,0 +1,78 @@
+package kv
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// pageToken encodes the path of the last key of a page relative to the
+// enumerated bucket.
+func pageToken(rel string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(rel))
+}
+
+// parsePageToken decodes a token returned by pageToken.
+func parsePageToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(b) == 0 {
+		return "", ErrInvalidToken
+	}
+
+	if keys, err := ParseKey(string(b)); err != nil || len(keys) == 0 {
+		return "", ErrInvalidToken
+	}
+
+	return string(b), nil
+}
+
+// checkPageSize returns an error unless pageSize is positive.
+func checkPageSize(pageSize int) error {
+	if pageSize <= 0 {
+		return fmt.Errorf("%w: page size %d is not positive", ErrInvalidArgument, pageSize)
+	}
+
+	return nil
+}
+
+// pageCollector collects keys of a page from a walk in sorted order starting
+// at after, which is skipped since it ended the previous page. It collects one
+// key beyond the page size in order to tell whether another page follows.
+type pageCollector struct {
+	prefix   string
+	after    string
+	pageSize int
+	rels     []string
+}
+
+func (p *pageCollector) add(rel string, _ []byte) error {
+	if rel == p.after {
+		return nil
+	}
+
+	p.rels = append(p.rels, rel)
+	if len(p.rels) > p.pageSize {
+		return errStop
+	}
+
+	return nil
+}
+
+// page returns keys of the page joined onto prefix and the token of the next page.
+func (p *pageCollector) page() ([]string, string) {
+	next := ""
+	if len(p.rels) > p.pageSize {
+		p.rels = p.rels[:p.pageSize]
+		next = pageToken(p.rels[len(p.rels)-1])
+	}
+
+	keys := make([]string, len(p.rels))
+	for i, rel := range p.rels {
+		keys[i] = joinKey(p.prefix, rel)
+	}
+
+	return keys, next
+}
@@ -0,0 +1,474 @@
+package resp
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sdeoras/kv"
+)
+
+// command runs a command given its arguments. maxArgs is -1 for commands
+// taking any number of arguments.
+type command struct {
+	minArgs, maxArgs int
+	f                func(db kv.KV, w writer, args [][]byte)
+}
+
+var commands = map[string]command{
+	"ping":    {0, 1, ping},
+	"echo":    {1, 1, echo},
+	"command": {0, -1, commandCmd},
+	"get":     {1, 1, get},
+	"set":     {2, -1, set},
+	"del":     {1, -1, del},
+	"exists":  {1, -1, exists},
+	"keys":    {1, 1, keys},
+	"scan":    {1, -1, scan},
+	"expire":  {2, 2, expire},
+	"ttl":     {1, 1, ttl},
+}
+
+func ping(_ kv.KV, w writer, args [][]byte) {
+	if len(args) == 1 {
+		w.bulk(args[0])
+		return
+	}
+
+	w.simple("PONG")
+}
+
+func echo(_ kv.KV, w writer, args [][]byte) {
+	w.bulk(args[0])
+}
+
+// commandCmd replies with no command docs, which redis-cli asks for on connect.
+func commandCmd(_ kv.KV, w writer, _ [][]byte) {
+	w.array(0)
+}
+
+func get(db kv.KV, w writer, args [][]byte) {
+	val, err := db.Get(string(args[0]))
+	switch {
+	case errors.Is(err, kv.ErrNotFound):
+		w.null()
+	case err != nil:
+		replyError(w, err)
+	default:
+		w.bulk(val)
+	}
+}
+
+// set implements SET key value [EX seconds|PX milliseconds] [NX|XX].
+func set(db kv.KV, w writer, args [][]byte) {
+	key, val := string(args[0]), args[1]
+
+	var ttl time.Duration
+	var nx, xx bool
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToLower(string(args[i])); opt {
+		case "nx":
+			nx = true
+		case "xx":
+			xx = true
+		case "ex", "px":
+			if i+1 == len(args) {
+				w.error("ERR syntax error")
+				return
+			}
+			i++
+			unit := time.Second
+			if opt == "px" {
+				unit = time.Millisecond
+			}
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil || n <= 0 || n > math.MaxInt64/int64(unit) {
+				w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			ttl = time.Duration(n) * unit
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+
+	if nx && xx {
+		w.error("ERR syntax error")
+		return
+	}
+
+	var err error
+	switch {
+	case (nx || xx) && ttl > 0:
+		var ok bool
+		if ok, err = setIf(db, key, val, ttl, nx); !ok {
+			w.error("ERR NX and XX with an expire time are not supported by this store")
+			return
+		}
+	case nx || xx:
+		c, ok := db.(kv.Conditional)
+		if !ok {
+			w.error("ERR NX and XX are not supported by this store")
+			return
+		}
+		if nx {
+			err = c.SetIfAbsent(key, val)
+		} else {
+			err = c.Replace(key, val)
+		}
+	case ttl > 0:
+		e, ok := db.(kv.Expirer)
+		if !ok {
+			w.error("ERR expiry is not supported by this store")
+			return
+		}
+		err = e.SetWithTTL(key, val, ttl)
+	default:
+		err = db.Set(key, val)
+	}
+
+	switch {
+	case nx && errors.Is(err, kv.ErrExists), xx && errors.Is(err, kv.ErrNotFound):
+		w.null()
+	case err != nil:
+		replyError(w, err)
+	default:
+		w.simple("OK")
+	}
+}
+
+// setIf sets a value expiring after ttl if key does not exist when nx is set,
+// or if it does otherwise, failing with kv.ErrExists or kv.ErrNotFound. The
+// check and the write are atomic within a transaction if db offers one.
+// Otherwise a kv.Conditional write is followed by setting the expiry, so
+// that a write made in between may be overwritten. It reports false if db
+// supports neither.
+func setIf(db kv.KV, key string, val []byte, ttl time.Duration, nx bool) (bool, error) {
+	if ok, err := inTx(db, func(tx kv.Tx, e kv.Expirer) error {
+		_, err := tx.Get(key)
+		switch {
+		case nx && err == nil:
+			return kv.ErrExists
+		case nx && errors.Is(err, kv.ErrNotFound), !nx && err == nil:
+			return e.SetWithTTL(key, val, ttl)
+		default:
+			return err
+		}
+	}); ok {
+		return true, err
+	}
+
+	c, ok := db.(kv.Conditional)
+	if !ok {
+		return false, nil
+	}
+
+	e, ok := db.(kv.Expirer)
+	if !ok {
+		return false, nil
+	}
+
+	var err error
+	if nx {
+		err = c.SetIfAbsent(key, val)
+	} else {
+		err = c.Replace(key, val)
+	}
+
+	if err != nil {
+		return true, err
+	}
+
+	return true, e.SetWithTTL(key, val, ttl)
+}
+
+// inTx runs f in a transaction of db along with the transaction as a
+// kv.Expirer, reporting false without running f if db has no such
+// transactions.
+func inTx(db kv.KV, f func(tx kv.Tx, e kv.Expirer) error) (bool, error) {
+	t, ok := db.(kv.Txn)
+	if !ok {
+		return false, nil
+	}
+
+	ran := false
+	err := t.Update(func(tx kv.Tx) error {
+		e, ok := tx.(kv.Expirer)
+		if !ok {
+			return nil
+		}
+
+		ran = true
+		return f(tx, e)
+	})
+
+	return ran, err
+}
+
+// exist tells whether key holds a value or a bucket.
+func exist(db kv.KV, key string) (bool, error) {
+	if s, ok := db.(kv.Stater); ok {
+		info, err := s.Stat(key)
+		return info.Exists, err
+	}
+
+	_, err := db.Get(key)
+	switch {
+	case err == nil, errors.Is(err, kv.ErrIsBucket):
+		return true, nil
+	case errors.Is(err, kv.ErrNotFound):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// del deletes keys, buckets included, replying with how many existed.
+func del(db kv.KV, w writer, args [][]byte) {
+	var n int64
+	for _, arg := range args {
+		key := string(arg)
+		ok, err := exist(db, key)
+		if err != nil {
+			replyError(w, err)
+			return
+		}
+
+		if !ok {
+			continue
+		}
+
+		if err := db.Delete(key); err != nil {
+			replyError(w, err)
+			return
+		}
+		n++
+	}
+
+	w.integer(n)
+}
+
+func exists(db kv.KV, w writer, args [][]byte) {
+	var n int64
+	for _, arg := range args {
+		ok, err := exist(db, string(arg))
+		if err != nil {
+			replyError(w, err)
+			return
+		}
+
+		if ok {
+			n++
+		}
+	}
+
+	w.integer(n)
+}
+
+// matching lists keys matching pattern, enumerating only the bucket every
+// match lies in.
+func matching(db kv.KV, pattern string) ([]string, error) {
+	all, err := db.Enumerate("/" + literalPrefix(pattern))
+	if errors.Is(err, kv.ErrNotFound) || errors.Is(err, kv.ErrPathIsValue) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(all))
+	for _, key := range all {
+		key = strings.TrimPrefix(key, "/")
+		if match(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+func keys(db kv.KV, w writer, args [][]byte) {
+	keys, err := matching(db, string(args[0]))
+	if err != nil {
+		replyError(w, err)
+		return
+	}
+
+	w.strings(keys)
+}
+
+// errInvalidCursor is returned by scanPage for cursors it did not hand out.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// scan implements SCAN cursor [MATCH pattern] [COUNT count], listing only
+// the bucket every match lies in.
+func scan(db kv.KV, w writer, args [][]byte) {
+	pattern, count := "*", 10
+	for i := 1; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			w.error("ERR syntax error")
+			return
+		}
+
+		switch strings.ToLower(string(args[i])) {
+		case "match":
+			pattern = string(args[i+1])
+		case "count":
+			var err error
+			count, err = strconv.Atoi(string(args[i+1]))
+			if err != nil || count < 1 {
+				w.error("ERR syntax error")
+				return
+			}
+		default:
+			w.error("ERR syntax error")
+			return
+		}
+	}
+
+	page, next, err := scanPage(db, "/"+literalPrefix(pattern), string(args[0]), count)
+	switch {
+	case errors.Is(err, errInvalidCursor), errors.Is(err, kv.ErrInvalidToken):
+		w.error("ERR invalid cursor")
+		return
+	case errors.Is(err, kv.ErrNotFound), errors.Is(err, kv.ErrPathIsValue):
+		page, next = nil, "0"
+	case err != nil:
+		replyError(w, err)
+		return
+	}
+
+	var keys []string
+	for _, key := range page {
+		key = strings.TrimPrefix(key, "/")
+		if match(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	w.array(2)
+	w.bulk([]byte(next))
+	w.strings(keys)
+}
+
+// scanPage lists up to count keys under prefix starting at cursor, returning
+// the cursor of the next page, which is 0 once all keys have been listed.
+// On a kv.Pager cursors are page tokens, so that a call only reads its page
+// and keys set or deleted in between do not shift pages. Otherwise cursors
+// are offsets into the sorted list of keys, which is enumerated on every call.
+func scanPage(db kv.KV, prefix, cursor string, count int) ([]string, string, error) {
+	if p, ok := db.(kv.Pager); ok {
+		token := cursor
+		if token == "0" {
+			token = ""
+		}
+
+		keys, next, err := p.EnumeratePage(prefix, count, token)
+		if next == "" {
+			next = "0"
+		}
+		return keys, next, err
+	}
+
+	offset, err := strconv.Atoi(cursor)
+	if err != nil || offset < 0 {
+		return nil, "", errInvalidCursor
+	}
+
+	all, err := db.Enumerate(prefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	end := offset + count
+	next := strconv.Itoa(end)
+	if end >= len(all) {
+		end, next = len(all), "0"
+	}
+
+	if offset >= end {
+		return nil, next, nil
+	}
+
+	return all[offset:end], next, nil
+}
+
+// expire sets a time to live on an existing key by setting its value again,
+// reading and writing it within a transaction if db offers one. A time to
+// live that is not positive deletes the key.
+func expire(db kv.KV, w writer, args [][]byte) {
+	key := string(args[0])
+	seconds, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		w.error("ERR value is not an integer or out of range")
+		return
+	}
+
+	if seconds > math.MaxInt64/int64(time.Second) {
+		w.error("ERR invalid expire time in 'expire' command")
+		return
+	}
+
+	e, ok := db.(kv.Expirer)
+	if !ok {
+		w.error("ERR expiry is not supported by this store")
+		return
+	}
+
+	ok, err = inTx(db, func(tx kv.Tx, e kv.Expirer) error {
+		return expireIn(tx, e, key, seconds)
+	})
+	if !ok {
+		err = expireIn(db, e, key, seconds)
+	}
+
+	switch {
+	case errors.Is(err, kv.ErrNotFound):
+		w.integer(0)
+	case err != nil:
+		replyError(w, err)
+	default:
+		w.integer(1)
+	}
+}
+
+// expireIn sets the value of key again within tx with a time to live of
+// seconds, or deletes key if seconds is not positive.
+func expireIn(tx kv.Tx, e kv.Expirer, key string, seconds int64) error {
+	val, err := tx.Get(key)
+	if err != nil {
+		return err
+	}
+
+	if seconds <= 0 {
+		return tx.Delete(key)
+	}
+
+	return e.SetWithTTL(key, val, time.Duration(seconds)*time.Second)
+}
+
+// ttl replies with the seconds a key has left, -1 if it does not expire
+// and -2 if it does not exist.
+func ttl(db kv.KV, w writer, args [][]byte) {
+	e, ok := db.(kv.Expirer)
+	if !ok {
+		w.error("ERR expiry is not supported by this store")
+		return
+	}
+
+	d, err := e.TTL(string(args[0]))
+	switch {
+	case errors.Is(err, kv.ErrNotFound):
+		w.integer(-2)
+	case err != nil:
+		replyError(w, err)
+	case d == 0:
+		w.integer(-1)
+	default:
+		// round up like Redis so that a key about to expire reports 1.
+		w.integer(int64((d + time.Second - 1) / time.Second))
+	}
+}
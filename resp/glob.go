@@ -0,0 +1,111 @@
+package resp
+
+// match reports whether s matches a glob pattern the way KEYS and SCAN do
+// in Redis: * matches any sequence, slashes included, ? matches a single
+// byte, [abc], [^abc] and [a-z] match classes of bytes and \ escapes.
+//
+// On a mismatch only the last * seen is backtracked to, letting it match one
+// more byte, which takes time proportional to len(pattern)*len(s) at most,
+// since matching past an earlier * never needs to be revisited.
+func match(pattern, s string) bool {
+	// star is the position in pattern following the last *, and next the
+	// position in s that * is currently matched up to, or star is -1.
+	star, next := -1, 0
+	p, i := 0, 0
+	for p < len(pattern) || i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				star, next = p+1, i
+				p++
+				continue
+			case '?':
+				if i < len(s) {
+					p, i = p+1, i+1
+					continue
+				}
+			case '[':
+				if i < len(s) {
+					if rest, ok := matchClass(pattern[p+1:], s[i]); ok {
+						p, i = len(pattern)-len(rest), i+1
+						continue
+					}
+				}
+			default:
+				c, width := pattern[p], 1
+				if c == '\\' && p+1 < len(pattern) {
+					c, width = pattern[p+1], 2
+				}
+				if i < len(s) && s[i] == c {
+					p, i = p+width, i+1
+					continue
+				}
+			}
+		}
+
+		if star < 0 || next == len(s) {
+			return false
+		}
+		next++
+		p, i = star, next
+	}
+
+	return true
+}
+
+// matchClass matches c against a class following an opening bracket,
+// returning the pattern after the closing bracket.
+func matchClass(pattern string, c byte) (string, bool) {
+	negate := len(pattern) > 0 && pattern[0] == '^'
+	if negate {
+		pattern = pattern[1:]
+	}
+
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		switch {
+		case pattern[0] == '\\' && len(pattern) > 1:
+			matched = matched || pattern[1] == c
+			pattern = pattern[2:]
+		case len(pattern) > 2 && pattern[1] == '-' && pattern[2] != ']':
+			lo, hi := pattern[0], pattern[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (lo <= c && c <= hi)
+			pattern = pattern[3:]
+		default:
+			matched = matched || pattern[0] == c
+			pattern = pattern[1:]
+		}
+	}
+
+	// an unterminated class runs to the end of the pattern.
+	if len(pattern) > 0 {
+		pattern = pattern[1:]
+	}
+
+	return pattern, matched != negate
+}
+
+// literalPrefix returns the bucket holding every key a pattern can match,
+// i.e. the part of the pattern before the last slash preceding its first
+// special character.
+func literalPrefix(pattern string) string {
+	end := len(pattern)
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?', '[', '\\':
+			end = i
+			i = len(pattern)
+		}
+	}
+
+	for i := end - 1; i >= 0; i-- {
+		if pattern[i] == '/' {
+			return pattern[:i]
+		}
+	}
+
+	return ""
+}
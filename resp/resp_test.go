@@ -0,0 +1,347 @@
+package resp_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sdeoras/kv"
+	"github.com/sdeoras/kv/resp"
+)
+
+// client sends commands to a RESP server and decodes replies into strings,
+// integers, nil, errors prefixed with '-' and slices of those.
+type client struct {
+	c net.Conn
+	r *bufio.Reader
+}
+
+// newServer serves db on a local port returning a connected client along
+// with a func stopping both.
+func newServer(t *testing.T, db kv.KV) (*client, func()) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = resp.Serve(l, db) }()
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		_ = l.Close()
+		t.Fatal(err)
+	}
+
+	return &client{c: c, r: bufio.NewReader(c)}, func() {
+		_ = c.Close()
+		_ = l.Close()
+	}
+}
+
+func (c *client) do(t *testing.T, args ...string) interface{} {
+	t.Helper()
+
+	cmd := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		cmd += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	if _, err := io.WriteString(c.c, cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := c.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return reply
+}
+
+func (c *client) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return line, nil
+	case ':':
+		return strconv.Atoi(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected reply: %s", line)
+	}
+}
+
+// expectReply fails the test unless a command is replied to with expected.
+func (c *client) expectReply(t *testing.T, expected interface{}, args ...string) {
+	t.Helper()
+
+	if reply := c.do(t, args...); !reflect.DeepEqual(reply, expected) {
+		t.Fatalf("%v: expected %#v, got: %#v", args, expected, reply)
+	}
+}
+
+// expectScan fails the test unless SCAN with opts lists expected keys in
+// order across every page, with no page larger than COUNT.
+func (c *client) expectScan(t *testing.T, expected []string, opts ...string) {
+	t.Helper()
+
+	count := 10
+	for i := 0; i+1 < len(opts); i += 2 {
+		if strings.EqualFold(opts[i], "count") {
+			count, _ = strconv.Atoi(opts[i+1])
+		}
+	}
+
+	var keys []string
+	cursor := "0"
+	for {
+		reply, ok := c.do(t, append([]string{"SCAN", cursor}, opts...)...).([]interface{})
+		if !ok || len(reply) != 2 {
+			t.Fatalf("unexpected reply to SCAN: %#v", reply)
+		}
+
+		page := reply[1].([]interface{})
+		if len(page) > count {
+			t.Fatalf("expected at most %d keys, got: %v", count, page)
+		}
+
+		for _, key := range page {
+			keys = append(keys, key.(string))
+		}
+
+		cursor = reply[0].(string)
+		if cursor == "0" {
+			break
+		}
+	}
+
+	if strings.Join(keys, " ") != strings.Join(expected, " ") {
+		t.Fatalf("SCAN %v: expected %v, got: %v", opts, expected, keys)
+	}
+}
+
+func TestServe(t *testing.T) {
+	c, done := newServer(t, kv.NewMemKv())
+	defer done()
+
+	c.expectReply(t, "PONG", "PING")
+	c.expectReply(t, "OK", "SET", "a/b/c/myKey", "val")
+	c.expectReply(t, "OK", "SET", "a/b/c/someOtherKey", "someOtherValue")
+	c.expectReply(t, "OK", "set", "a/x", "")
+	c.expectReply(t, "val", "GET", "a/b/c/myKey")
+	c.expectReply(t, "", "GET", "a/x")
+	c.expectReply(t, nil, "GET", "a/missing")
+	c.expectReply(t, "-WRONGTYPE get a/b: key points to a bucket, not a value", "GET", "a/b")
+	c.expectReply(t, 2, "EXISTS", "a/b", "a/x", "a/missing")
+
+	c.expectReply(t, []interface{}{"a/b/c/myKey", "a/b/c/someOtherKey", "a/x"}, "KEYS", "*")
+	c.expectReply(t, []interface{}{"a/b/c/myKey", "a/b/c/someOtherKey"}, "KEYS", "a/b/*")
+	c.expectReply(t, []interface{}{"a/b/c/someOtherKey"}, "KEYS", "a/?/c/[rs]*")
+	c.expectReply(t, []interface{}{}, "KEYS", "z/*")
+
+	c.expectScan(t, []string{"a/b/c/myKey", "a/b/c/someOtherKey", "a/x"}, "COUNT", "2")
+	c.expectScan(t, []string{"a/x"}, "MATCH", "*x")
+	c.expectScan(t, []string{"a/b/c/myKey"}, "MATCH", "a/b/c/m*", "COUNT", "1")
+	c.expectReply(t, []interface{}{"0", []interface{}{}}, "SCAN", "0", "MATCH", "z/*")
+	c.expectReply(t, "-ERR invalid cursor", "SCAN", "bogus")
+
+	c.expectReply(t, nil, "SET", "a/x", "new", "NX")
+	c.expectReply(t, "OK", "SET", "a/x", "new", "XX")
+	c.expectReply(t, nil, "SET", "a/y", "new", "XX")
+	c.expectReply(t, "OK", "SET", "a/y", "new", "NX")
+
+	c.expectReply(t, -1, "TTL", "a/y")
+	c.expectReply(t, 1, "EXPIRE", "a/y", "100")
+	c.expectReply(t, 100, "TTL", "a/y")
+	c.expectReply(t, "new", "GET", "a/y")
+	c.expectReply(t, 0, "EXPIRE", "a/missing", "100")
+	c.expectReply(t, -2, "TTL", "a/missing")
+	c.expectReply(t, "OK", "SET", "a/z", "val", "EX", "10")
+	c.expectReply(t, 10, "TTL", "a/z")
+
+	c.expectReply(t, 2, "DEL", "a/b", "a/x", "a/missing")
+	c.expectReply(t, []interface{}{"a/y", "a/z"}, "KEYS", "*")
+
+	c.expectReply(t, "-ERR unknown command 'FOO'", "FOO")
+	c.expectReply(t, "-ERR wrong number of arguments for 'get' command", "GET")
+	c.expectReply(t, "-ERR syntax error", "SET", "a/x", "val", "EX")
+	c.expectReply(t, "-ERR invalid expire time in 'set' command", "SET", "a/x", "val", "EX", "9223372037")
+	c.expectReply(t, "-ERR invalid expire time in 'set' command", "SET", "a/x", "val", "PX", "9223372036855")
+	c.expectReply(t, "-ERR invalid expire time in 'expire' command", "EXPIRE", "a/y", "9223372037")
+	c.expectReply(t, 100, "TTL", "a/y")
+	c.expectReply(t, "OK", "QUIT")
+}
+
+func TestServe_KeysBacktracking(t *testing.T) {
+	c, done := newServer(t, kv.NewMemKv())
+	defer done()
+
+	long := strings.Repeat("a", 200)
+	c.expectReply(t, "OK", "SET", "k/"+long, "val")
+	c.expectReply(t, "OK", "SET", "k/a*b", "val")
+
+	// a matcher backtracking into every * would take exponential time here.
+	c.expectReply(t, []interface{}{}, "KEYS", "k/"+strings.Repeat("*a", 12)+"*c")
+	c.expectReply(t, []interface{}{"k/" + long}, "KEYS", "k/"+strings.Repeat("*a", 12)+"*")
+	c.expectReply(t, []interface{}{"k/a*b"}, "KEYS", `k/a\*b`)
+	c.expectReply(t, []interface{}{"k/a*b"}, "KEYS", "*[*]?")
+	c.expectReply(t, []interface{}{"k/a*b", "k/" + long}, "KEYS", "k/a**")
+}
+
+// kvOnly hides every optional interface of the KV it wraps.
+type kvOnly struct {
+	kv.KV
+}
+
+func TestServe_ScanOffset(t *testing.T) {
+	db := kv.NewMemKv()
+	for _, key := range []string{"a/1", "a/2", "a/3"} {
+		if err := db.Set(key, []byte("val")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, done := newServer(t, kvOnly{db})
+	defer done()
+
+	c.expectReply(t, []interface{}{"2", []interface{}{"a/1", "a/2"}}, "SCAN", "0", "COUNT", "2")
+	c.expectReply(t, []interface{}{"0", []interface{}{"a/3"}}, "SCAN", "2", "COUNT", "2")
+	c.expectScan(t, []string{"a/1", "a/2", "a/3"}, "COUNT", "1")
+}
+
+// noTxn hides kv.Txn, leaving conditional writes and expiry.
+type noTxn struct {
+	kv.KV
+	kv.Conditional
+	kv.Expirer
+}
+
+func TestServe_SetIfWithTTL(t *testing.T) {
+	mem := kv.NewMemKv()
+	other := kv.NewMemKv()
+	for _, db := range []kv.KV{mem, noTxn{other, other.(kv.Conditional), other.(kv.Expirer)}} {
+		c, done := newServer(t, db)
+
+		c.expectReply(t, "OK", "SET", "a/lock", "owner1", "NX", "EX", "10")
+		c.expectReply(t, nil, "SET", "a/lock", "owner2", "NX", "EX", "10")
+		c.expectReply(t, "owner1", "GET", "a/lock")
+		c.expectReply(t, 10, "TTL", "a/lock")
+		c.expectReply(t, "OK", "SET", "a/lock", "owner1", "XX", "PX", "20000")
+		c.expectReply(t, 20, "TTL", "a/lock")
+		c.expectReply(t, nil, "SET", "a/missing", "val", "XX", "EX", "10")
+		c.expectReply(t, -2, "TTL", "a/missing")
+		c.expectReply(t, 1, "EXPIRE", "a/lock", "30")
+		c.expectReply(t, 30, "TTL", "a/lock")
+		c.expectReply(t, 1, "EXPIRE", "a/lock", "0")
+		c.expectReply(t, nil, "GET", "a/lock")
+
+		done()
+	}
+
+	c, done := newServer(t, kvOnly{mem})
+	defer done()
+	c.expectReply(t, "-ERR NX and XX with an expire time are not supported by this store",
+		"SET", "a/lock", "owner1", "NX", "EX", "10")
+}
+
+func TestServe_Inline(t *testing.T) {
+	c, done := newServer(t, kv.NewMemKv())
+	defer done()
+
+	if _, err := io.WriteString(c.c, "SET a/b val\r\nGET a/b\r\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"OK", "val"} {
+		reply, err := c.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if reply != expected {
+			t.Fatalf("expected %s, got: %v", expected, reply)
+		}
+	}
+}
+
+func TestServe_ProtocolError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() { _ = resp.Serve(l, kv.NewMemKv()) }()
+
+	dial := func() *client {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &client{c: c, r: bufio.NewReader(c)}
+	}
+
+	for _, test := range []struct {
+		request  string
+		expected string
+	}{
+		{"*-1\r\n", "-ERR protocol error: invalid multibulk length"},
+		{"*2000000\r\n", "-ERR protocol error: invalid multibulk length"},
+		{"*1\r\n$-1\r\n", "-ERR protocol error: invalid bulk length"},
+		{"*1\r\n$536870913\r\n", "-ERR protocol error: invalid bulk length"},
+		{strings.Repeat("a", 64<<10+1) + "\r\n", "-ERR protocol error: too big inline request"},
+	} {
+		c := dial()
+		if _, err := io.WriteString(c.c, test.request); err != nil {
+			t.Fatal(err)
+		}
+
+		reply, err := c.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = c.c.Close()
+
+		if reply != test.expected {
+			t.Fatalf("%.20q: expected %s, got: %v", test.request, test.expected, reply)
+		}
+	}
+
+	// the server keeps serving other clients.
+	c := dial()
+	defer c.c.Close()
+	c.expectReply(t, "PONG", "PING")
+}
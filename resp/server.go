@@ -0,0 +1,110 @@
+// Package resp serves any kv.KV over RESP, the protocol spoken by Redis, so
+// that redis-cli and other Redis tools can inspect and edit a store. Keys are
+// the slash separated paths of package kv, without their leading slash.
+//
+// Supported commands are GET, SET with EX, PX, NX and XX, DEL, EXISTS, KEYS,
+// SCAN with MATCH and COUNT, EXPIRE, TTL, PING, ECHO and QUIT. SET with NX or
+// XX requires a kv.Conditional, and expiry requires a kv.Expirer. SET with NX
+// or XX along with an expiry, as used for locks and leases, and EXPIRE are
+// atomic on a kv.Txn whose transactions are kv.Expirers.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/sdeoras/kv"
+)
+
+// Serve accepts connections on l and serves db on each until l is closed.
+// Connections still open at that point are closed as well.
+func Serve(l net.Listener, db kv.KV) error {
+	var mu sync.Mutex
+	conns := make(map[net.Conn]bool)
+	defer func() {
+		mu.Lock()
+		for c := range conns {
+			_ = c.Close()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		conns[c] = true
+		mu.Unlock()
+
+		go func() {
+			serveConn(c, db)
+
+			mu.Lock()
+			delete(conns, c)
+			mu.Unlock()
+		}()
+	}
+}
+
+// serveConn serves commands sent over c until it is closed or QUIT is sent.
+func serveConn(c net.Conn, db kv.KV) {
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	w := writer{bufio.NewWriter(c)}
+	for {
+		args, err := readCommand(r)
+		if errors.Is(err, errProtocol) {
+			w.error("ERR " + err.Error())
+			_ = w.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		name := strings.ToLower(string(args[0]))
+		if name == "quit" {
+			w.simple("OK")
+			_ = w.Flush()
+			return
+		}
+
+		cmd, ok := commands[name]
+		switch {
+		case !ok:
+			w.error("ERR unknown command '" + string(args[0]) + "'")
+		case len(args)-1 < cmd.minArgs || (cmd.maxArgs >= 0 && len(args)-1 > cmd.maxArgs):
+			w.error("ERR wrong number of arguments for '" + name + "' command")
+		default:
+			cmd.f(db, w, args[1:])
+		}
+
+		// replies to pipelined commands are flushed together.
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// replyError writes err as an error reply in the style of Redis.
+func replyError(w writer, err error) {
+	switch {
+	case errors.Is(err, kv.ErrIsBucket), errors.Is(err, kv.ErrPathIsValue):
+		w.error("WRONGTYPE " + err.Error())
+	default:
+		w.error("ERR " + err.Error())
+	}
+}
@@ -0,0 +1,181 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// errStop stops a walk early once enough keys have been collected.
+var errStop = errors.New("stop walking")
+
+// cursor iterates over the children of a bucket in sorted order. Positioning
+// methods return the name of the child the cursor lands on and whether it holds
+// a value, with an empty name once the cursor runs off either end.
+type cursor interface {
+	First() (string, bool)
+	Last() (string, bool)
+	// Seek moves to the first child whose name is not less than name.
+	Seek(name string) (string, bool)
+	Next() (string, bool)
+	Prev() (string, bool)
+	// Value returns the value of the current child.
+	Value() []byte
+	// Bucket returns a cursor over the children of the current child.
+	Bucket() cursor
+}
+
+// scanRange walks leaves below c in sorted order, or in reverse, calling f for
+// each with its path relative to the bucket prefixed with rel. Only leaves whose
+// relative path segments lie within [start, end) are visited, where an empty
+// bound is open. ctx is checked at every bucket so that the walk can be cancelled.
+func scanRange(ctx context.Context, c cursor, rel string, start, end []string, reverse bool,
+	f func(rel string, val []byte) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var name string
+	var leaf bool
+	switch {
+	case !reverse && len(start) > 0:
+		name, leaf = c.Seek(start[0])
+	case !reverse:
+		name, leaf = c.First()
+	case len(end) > 0:
+		name, leaf = c.Seek(end[0])
+		if name == "" {
+			name, leaf = c.Last()
+		} else if name > end[0] {
+			name, leaf = c.Prev()
+		}
+	default:
+		name, leaf = c.Last()
+	}
+
+	next := c.Next
+	if reverse {
+		next = c.Prev
+	}
+
+	for ; name != ""; name, leaf = next() {
+		// below tells whether the child sorts before start and above whether it
+		// sorts at or after end. A leaf sorts before any path it is a prefix of,
+		// while everything in a bucket sorts after the bucket itself.
+		var subStart, subEnd []string
+		below, above := false, false
+
+		if len(start) > 0 {
+			switch {
+			case name < start[0]:
+				below = true
+			case name == start[0] && leaf:
+				below = len(start) > 1
+			case name == start[0]:
+				subStart = start[1:]
+			}
+		}
+
+		if len(end) > 0 {
+			switch {
+			case name > end[0]:
+				above = true
+			case name == end[0] && leaf:
+				above = len(end) == 1
+			case name == end[0] && len(end) == 1:
+				above = true
+			case name == end[0]:
+				subEnd = end[1:]
+			}
+		}
+
+		// children only move further out of range in walking order
+		if (below && reverse) || (above && !reverse) {
+			return nil
+		}
+
+		if below || above {
+			continue
+		}
+
+		if leaf {
+			if err := f(joinKey(rel, escapeSegment(name)), c.Value()); err != nil {
+				return err
+			}
+		} else {
+			if err := scanRange(ctx, c.Bucket(), joinKey(rel, escapeSegment(name)), subStart, subEnd, reverse, f); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// scanBounds parses the start and end bounds of a scan.
+func scanBounds(start, end string) (Key, Key, error) {
+	s, err := splitKey(start)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	e, err := splitKey(end)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s, e, nil
+}
+
+// copyValues wraps f so that it is passed copies of values, which backends
+// otherwise share with their snapshots or map read-only.
+func copyValues(f func(key string, val []byte) error) func(key string, val []byte) error {
+	return func(key string, val []byte) error {
+		v := make([]byte, len(val))
+		copy(v, val)
+		return f(key, v)
+	}
+}
+
+// scanCollector collects pairs from a walk until limit is reached.
+type scanCollector struct {
+	prefix string
+	limit  int
+	values bool
+	pairs  []Pair
+}
+
+func (s *scanCollector) add(rel string, val []byte) error {
+	p := Pair{Key: joinKey(s.prefix, rel)}
+	if s.values {
+		p.Value = make([]byte, len(val))
+		copy(p.Value, val)
+	}
+
+	s.pairs = append(s.pairs, p)
+	if s.limit > 0 && len(s.pairs) >= s.limit {
+		return errStop
+	}
+
+	return nil
+}
+
+// keys returns keys of collected pairs.
+func (s *scanCollector) keys() []string {
+	keys := make([]string, len(s.pairs))
+	for i, p := range s.pairs {
+		keys[i] = p.Key
+	}
+
+	return keys
+}
+
+// lessKey orders escaped paths built by this package as defined by Key.Less.
+func lessKey(a, b string) bool {
+	return splitPath(a).Less(splitPath(b))
+}
+
+// sortKeys sorts keys in the order defined by lessKey.
+func sortKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+}
@@ -0,0 +1,19 @@
+package kv
+
+import "errors"
+
+// statResult returns info for a key that was looked up with err, where a
+// key that does not exist is not an error but an info with Exists unset.
+func statResult(key string, info Info, err error) (Info, error) {
+	if errors.Is(err, ErrNotFound) {
+		return Info{Key: key}, nil
+	}
+
+	if err != nil {
+		return Info{}, keyError("stat", key, err)
+	}
+
+	info.Key = key
+	info.Exists = true
+	return info, nil
+}
@@ -0,0 +1,267 @@
+package kv
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Format is an encoding of keys written by Export and read by Import.
+type Format int
+
+const (
+	// JSONLines writes one JSON object per line holding a key relative to
+	// the exported prefix and its value in base64:
+	//	{"key":"/b/c/myKey","value":"dmFs"}
+	JSONLines Format = iota
+	// Tar writes a tar archive with a regular file per key, named after the
+	// key relative to the exported prefix without its leading slash.
+	Tar
+)
+
+// ConflictPolicy decides what Import does with keys that already exist.
+type ConflictPolicy int
+
+const (
+	// Overwrite replaces existing keys.
+	Overwrite ConflictPolicy = iota
+	// Skip leaves existing keys untouched and carries on.
+	Skip
+	// Fail stops at the first existing key with ErrExists. Keys imported
+	// until then are kept.
+	Fail
+)
+
+// TransferOption configures Export and Import.
+type TransferOption func(*transfer)
+
+type transfer struct {
+	format   Format
+	progress func(key string, n int)
+}
+
+// WithFormat sets the format of an export or import, which defaults to JSONLines.
+func WithFormat(f Format) TransferOption {
+	return func(t *transfer) {
+		t.format = f
+	}
+}
+
+// WithProgress sets a func called after every key that is exported or imported,
+// or skipped by Import, along with the number of keys processed so far.
+func WithProgress(f func(key string, n int)) TransferOption {
+	return func(t *transfer) {
+		t.progress = f
+	}
+}
+
+// newTransfer applies opts over the defaults.
+func newTransfer(opts []TransferOption) *transfer {
+	t := &transfer{format: JSONLines, progress: func(string, int) {}}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// record is a key and value as written by JSONLines.
+type record struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Export writes every key under prefix, which must be a bucket, to w along
+// with its value. Keys are written relative to prefix in sorted order, so that
+// they can be imported under another prefix or into another backend. Export
+// works with any KV, streaming keys if it implements Iterator.
+func Export(db KV, prefix string, w io.Writer, opts ...TransferOption) error {
+	t := newTransfer(opts)
+
+	p, err := splitKey(prefix)
+	if err != nil {
+		return keyError("export", prefix, err)
+	}
+
+	var write func(rel Key, val []byte) error
+	var flush func() error
+	switch t.format {
+	case JSONLines:
+		enc := json.NewEncoder(w)
+		write = func(rel Key, val []byte) error {
+			return enc.Encode(record{Key: rel.String(), Value: val})
+		}
+		flush = func() error { return nil }
+	case Tar:
+		tw := tar.NewWriter(w)
+		write = func(rel Key, val []byte) error {
+			h := &tar.Header{Name: rel.path(), Mode: 0644, Size: int64(len(val)), Typeflag: tar.TypeReg}
+			if err := tw.WriteHeader(h); err != nil {
+				return err
+			}
+			_, err := tw.Write(val)
+			return err
+		}
+		flush = tw.Close
+	default:
+		return keyError("export", prefix, fmt.Errorf("unknown format:%v", t.format))
+	}
+
+	n := 0
+	f := func(key string, val []byte) error {
+		keys := splitPath(key)
+		if len(keys) == len(p) {
+			return ErrPathIsValue
+		}
+
+		if err := write(keys[len(p):], val); err != nil {
+			return err
+		}
+
+		n++
+		t.progress(key, n)
+		return nil
+	}
+
+	if it, ok := db.(Iterator); ok {
+		err = it.Iterate(context.Background(), prefix, f)
+	} else {
+		err = exportKeys(db, prefix, f)
+	}
+
+	if err != nil {
+		return keyError("export", prefix, err)
+	}
+
+	return keyError("export", prefix, flush())
+}
+
+// exportKeys calls f for every key under prefix using Enumerate and Get,
+// skipping keys deleted in between.
+func exportKeys(db KV, prefix string, f func(key string, val []byte) error) error {
+	keys, err := db.Enumerate(prefix)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		val, err := db.Get(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := f(key, val); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Import reads keys written by Export from r and sets them under prefix,
+// resolving keys that already exist according to policy. Import is not atomic
+// and works with any KV.
+func Import(db KV, prefix string, r io.Reader, policy ConflictPolicy, opts ...TransferOption) error {
+	t := newTransfer(opts)
+
+	p, err := splitKey(prefix)
+	if err != nil {
+		return keyError("import", prefix, err)
+	}
+
+	var next func() (string, []byte, error)
+	switch t.format {
+	case JSONLines:
+		dec := json.NewDecoder(r)
+		next = func() (string, []byte, error) {
+			var rec record
+			if err := dec.Decode(&rec); err != nil {
+				return "", nil, err
+			}
+			return rec.Key, rec.Value, nil
+		}
+	case Tar:
+		tr := tar.NewReader(r)
+		next = func() (string, []byte, error) {
+			for {
+				h, err := tr.Next()
+				if err != nil {
+					return "", nil, err
+				}
+
+				// directories are implied by file names.
+				if !h.FileInfo().Mode().IsRegular() {
+					continue
+				}
+
+				val, err := ioutil.ReadAll(tr)
+				if err != nil {
+					return "", nil, err
+				}
+				return strings.TrimPrefix(h.Name, "./"), val, nil
+			}
+		}
+	default:
+		return keyError("import", prefix, fmt.Errorf("unknown format:%v", t.format))
+	}
+
+	for n := 1; ; n++ {
+		name, val, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return keyError("import", prefix, err)
+		}
+
+		rel, err := ParseKey(name)
+		if err != nil {
+			return keyError("import", name, err)
+		}
+
+		if len(rel) == 0 {
+			return keyError("import", name, ErrEmptyKey)
+		}
+
+		key := append(append(Key(nil), p...), rel...).String()
+		if err := importKey(db, key, val, policy); err != nil {
+			return keyError("import", key, err)
+		}
+
+		t.progress(key, n)
+	}
+}
+
+// importKey sets a single key according to policy.
+func importKey(db KV, key string, val []byte, policy ConflictPolicy) error {
+	switch policy {
+	case Overwrite:
+		return db.Set(key, val)
+	case Skip, Fail:
+	default:
+		return fmt.Errorf("unknown conflict policy:%v", policy)
+	}
+
+	var err error
+	if c, ok := db.(Conditional); ok {
+		err = c.SetIfAbsent(key, val)
+	} else if _, err = db.Get(key); err == nil || errors.Is(err, ErrIsBucket) {
+		err = ErrExists
+	} else if errors.Is(err, ErrNotFound) {
+		err = db.Set(key, val)
+	}
+
+	if errors.Is(err, ErrExists) && policy == Skip {
+		return nil
+	}
+
+	return err
+}
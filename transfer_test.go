@@ -0,0 +1,199 @@
+package kv_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sdeoras/kv"
+)
+
+// newTransferKv returns a mem kv holding a few keys under /a.
+func newTransferKv(t *testing.T) kv.KV {
+	t.Helper()
+
+	db := kv.NewMemKv()
+	for key, val := range map[string]string{
+		"/a/b/c/myKey":    "val",
+		"/a/b/otherKey":   "otherVal",
+		`/a/x\/y`:         "escaped",
+		"/a/empty":        "",
+		"/elsewhere/skip": "skip",
+	} {
+		if err := db.Set(key, []byte(val)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return db
+}
+
+// expectTransferred fails the test unless db holds exactly the keys of
+// newTransferKv under /a below prefix.
+func expectTransferred(t *testing.T, db kv.KV, prefix string) {
+	t.Helper()
+
+	keys, err := db.Enumerate(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{prefix + "/b/c/myKey", prefix + "/b/otherKey", prefix + "/empty", prefix + `/x\/y`}
+	if strings.Join(keys, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected keys %v, got: %v", expected, keys)
+	}
+
+	b, err := db.Get(prefix + `/x\/y`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "escaped" {
+		t.Fatal("expected escaped, got:", string(b))
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	for _, format := range []kv.Format{kv.JSONLines, kv.Tar} {
+		src := newTransferKv(t)
+
+		var buf bytes.Buffer
+		n := 0
+		if err := kv.Export(src, "/a", &buf, kv.WithFormat(format),
+			kv.WithProgress(func(key string, count int) { n = count })); err != nil {
+			t.Fatal(err)
+		}
+
+		if n != 4 {
+			t.Fatal("expected progress for 4 keys, got:", n)
+		}
+
+		dst := kv.NewMemKv()
+		if err := kv.Import(dst, "/restored", &buf, kv.Fail, kv.WithFormat(format)); err != nil {
+			t.Fatal(err)
+		}
+
+		expectTransferred(t, dst, "/restored")
+	}
+}
+
+func TestExport_JSONLines(t *testing.T) {
+	db := kv.NewMemKv()
+	if err := db.Set("/a/b/c/myKey", []byte("val")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := kv.Export(db, "/a/b", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := `{"key":"/c/myKey","value":"dmFs"}` + "\n"; buf.String() != expected {
+		t.Fatalf("expected %q, got: %q", expected, buf.String())
+	}
+}
+
+func TestExport_Tar(t *testing.T) {
+	db := newTransferKv(t)
+
+	var buf bytes.Buffer
+	if err := kv.Export(db, "/a/b", &buf, kv.WithFormat(kv.Tar)); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		h, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, h.Name)
+	}
+
+	if strings.Join(names, " ") != "c/myKey otherKey" {
+		t.Fatal("unexpected file names:", names)
+	}
+}
+
+func TestExport_Leaf(t *testing.T) {
+	db := newTransferKv(t)
+
+	if err := kv.Export(db, "/a/b/otherKey", &bytes.Buffer{}); !errors.Is(err, kv.ErrPathIsValue) {
+		t.Fatal("expected ErrPathIsValue, got:", err)
+	}
+}
+
+func TestImport_Policy(t *testing.T) {
+	src := newTransferKv(t)
+
+	var buf bytes.Buffer
+	if err := kv.Export(src, "/a", &buf); err != nil {
+		t.Fatal(err)
+	}
+	dump := buf.String()
+
+	tests := []struct {
+		policy   kv.ConflictPolicy
+		expected string
+		err      error
+	}{
+		{kv.Overwrite, "val", nil},
+		{kv.Skip, "existing", nil},
+		{kv.Fail, "existing", kv.ErrExists},
+	}
+
+	for _, test := range tests {
+		dst := kv.NewMemKv()
+		if err := dst.Set("/a/b/c/myKey", []byte("existing")); err != nil {
+			t.Fatal(err)
+		}
+
+		err := kv.Import(dst, "/a", strings.NewReader(dump), test.policy)
+		if test.err == nil && err != nil {
+			t.Fatal(err)
+		}
+
+		if test.err != nil && !errors.Is(err, test.err) {
+			t.Fatalf("expected error %q, got: %v", test.err, err)
+		}
+
+		b, err := dst.Get("/a/b/c/myKey")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != test.expected {
+			t.Fatalf("policy %v: expected %s, got: %s", test.policy, test.expected, string(b))
+		}
+
+		if test.err == nil {
+			expectTransferred(t, dst, "/a")
+		}
+	}
+}
+
+func TestImport_Invalid(t *testing.T) {
+	for _, line := range []string{
+		`{"key":"/../etc/passwd","value":"dmFs"}`,
+		`{"key":"/","value":"dmFs"}`,
+		`{"key":"/a"}`,
+		`not json`,
+	} {
+		db := kv.NewMemKv()
+		if err := kv.Import(db, "/", strings.NewReader(line), kv.Overwrite); err == nil {
+			t.Fatal("expected error importing", line)
+		}
+
+		keys, err := db.Enumerate("/")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(keys) != 0 {
+			t.Fatal("expected no keys, got:", keys)
+		}
+	}
+}
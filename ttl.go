@@ -0,0 +1,48 @@
+package kv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// checkTTL returns an error unless ttl is positive.
+func checkTTL(ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("%w: ttl %v is not positive", ErrInvalidArgument, ttl)
+	}
+
+	return nil
+}
+
+// expired reports whether a key expiring at expires has expired at now,
+// where a zero expires never expires.
+func expired(expires, now time.Time) bool {
+	return !expires.IsZero() && !now.Before(expires)
+}
+
+// timeLeft returns the time left at now before expires, or zero if expires
+// is zero.
+func timeLeft(expires, now time.Time) time.Duration {
+	if expires.IsZero() {
+		return 0
+	}
+
+	return expires.Sub(now)
+}
+
+// encodeExpiry encodes an expiry time as unix nanoseconds.
+func encodeExpiry(t time.Time) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(t.UnixNano()))
+	return b
+}
+
+// decodeExpiry decodes an expiry time encoded by encodeExpiry.
+func decodeExpiry(b []byte) time.Time {
+	if len(b) != 8 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, int64(binary.BigEndian.Uint64(b)))
+}
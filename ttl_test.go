@@ -0,0 +1,128 @@
+package kv_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sdeoras/kv"
+	"github.com/sdeoras/kv/kvtest"
+)
+
+// testJanitor checks that the janitor reclaims expired keys on a tick. Once
+// reclaimed, a key stays gone even if the clock is turned back.
+func testJanitor(t *testing.T, db kv.KV, clock *kvtest.Clock, ticks chan<- time.Time) {
+	e := db.(kv.Expirer)
+	if err := e.SetWithTTL("/a/b/c/myKey", []byte("val"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// a key left to expire keeps the janitor running.
+	if err := e.SetWithTTL("/a/b/c/later", []byte("val"), 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Set("/a/b/c/someOtherKey", []byte("val")); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Add(time.Hour)
+	if _, err := db.Get("/a/b/c/myKey"); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatal("expected ErrNotFound, got:", err)
+	}
+
+	// the second tick is taken once the first sweep is done.
+	ticks <- clock.Now()
+	ticks <- clock.Now()
+
+	clock.Add(-time.Hour)
+	if _, err := db.Get("/a/b/c/myKey"); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatal("expected expired key to be reclaimed, got:", err)
+	}
+
+	for _, key := range []string{"/a/b/c/later", "/a/b/c/someOtherKey"} {
+		if _, err := db.Get(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMemKv_Janitor(t *testing.T) {
+	clock, ticks := kvtest.NewClock(), make(chan time.Time)
+	db := kv.NewMemKv(kv.WithClock(clock.Now), kv.WithTicks(ticks))
+	testJanitor(t, db, clock, ticks)
+}
+
+func TestBoltKv_Janitor(t *testing.T) {
+	clock, ticks := kvtest.NewClock(), make(chan time.Time)
+	db, _, done := newBoltKv(t, kv.WithClock(clock.Now), kv.WithTicks(ticks))
+	defer done()
+
+	testJanitor(t, db, clock, ticks)
+}
+
+// testJanitorTxn checks that the janitor reclaims keys set to expire within
+// a transaction.
+func testJanitorTxn(t *testing.T, db kv.KV, clock *kvtest.Clock, ticks chan<- time.Time) {
+	if err := db.(kv.Txn).Update(func(tx kv.Tx) error {
+		e := tx.(kv.Expirer)
+		if err := e.SetWithTTL("/a/b/c/myKey", []byte("val"), time.Minute); err != nil {
+			return err
+		}
+
+		// a key left to expire keeps the janitor running.
+		return e.SetWithTTL("/a/b/c/later", []byte("val"), 2*time.Hour)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Add(time.Hour)
+
+	// the second tick is taken once the first sweep is done.
+	ticks <- clock.Now()
+	ticks <- clock.Now()
+
+	clock.Add(-time.Hour)
+	if _, err := db.Get("/a/b/c/myKey"); !errors.Is(err, kv.ErrNotFound) {
+		t.Fatal("expected expired key to be reclaimed, got:", err)
+	}
+
+	if _, err := db.Get("/a/b/c/later"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemKv_JanitorTxn(t *testing.T) {
+	clock, ticks := kvtest.NewClock(), make(chan time.Time)
+	db := kv.NewMemKv(kv.WithClock(clock.Now), kv.WithTicks(ticks))
+	testJanitorTxn(t, db, clock, ticks)
+}
+
+func TestBoltKv_JanitorTxn(t *testing.T) {
+	clock, ticks := kvtest.NewClock(), make(chan time.Time)
+	db, _, done := newBoltKv(t, kv.WithClock(clock.Now), kv.WithTicks(ticks))
+	defer done()
+
+	testJanitorTxn(t, db, clock, ticks)
+}
+
+func TestWithJanitorInterval_NotPositive(t *testing.T) {
+	// a janitor ticking at an interval that is not positive would panic in
+	// the background, taking the test binary down.
+	for _, d := range []time.Duration{0, -time.Second} {
+		mem := kv.NewMemKv(kv.WithJanitorInterval(d))
+		bolt, _, done := newBoltKv(t, kv.WithJanitorInterval(d))
+
+		for _, db := range []kv.KV{mem, bolt} {
+			if err := db.(kv.Expirer).SetWithTTL("/a/b", []byte("val"), time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := db.Get("/a/b"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		done()
+	}
+}
@@ -0,0 +1,91 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// Version is an opaque revision of a value returned by GetWithVersion.
+// Every write gives a key a new revision, so that a version goes stale once
+// the key is written again, even with the same bytes. The directory backend
+// has nowhere to keep revisions and derives versions from the content of
+// values instead, so that compare-and-swap compares values there. The zero
+// Version stands for a key that does not exist.
+type Version string
+
+// versionOf returns the version of a value derived from its content, which
+// versions values that have no revision recorded.
+func versionOf(val []byte) Version {
+	h := fnv.New64a()
+	_, _ = h.Write(val)
+	return Version(fmt.Sprintf("%016x", h.Sum64()))
+}
+
+// revisionVersion returns the version of a value written at revision rev.
+func revisionVersion(rev uint64) Version {
+	return Version(fmt.Sprintf("r%d", rev))
+}
+
+// revisionTx is implemented by transactions recording a revision along with
+// every value they write.
+type revisionTx interface {
+	Tx
+	// getRevision gets a value along with the version of the revision it was
+	// last written at.
+	getRevision(key string) ([]byte, Version, error)
+}
+
+// getWithVersion gets a value along with its version within tx.
+func getWithVersion(tx Tx, key string) ([]byte, Version, error) {
+	if r, ok := tx.(revisionTx); ok {
+		return r.getRevision(key)
+	}
+
+	val, err := tx.Get(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return val, versionOf(val), nil
+}
+
+// checkVersion returns ErrConflict unless key is currently at version within tx.
+func checkVersion(tx Tx, op, key string, version Version) error {
+	_, current, err := getWithVersion(tx, key)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if version != "" {
+			return keyError(op, key, ErrConflict)
+		}
+		return nil
+	case err != nil:
+		return err
+	case version != current:
+		return keyError(op, key, ErrConflict)
+	default:
+		return nil
+	}
+}
+
+// setIfVersion sets a value within tx if key is currently at version.
+func setIfVersion(tx Tx, key string, val []byte, version Version) error {
+	if err := checkVersion(tx, "set", key, version); err != nil {
+		return err
+	}
+
+	return tx.Set(key, val)
+}
+
+// deleteIfVersion deletes a key within tx if it is currently at version.
+func deleteIfVersion(tx Tx, key string, version Version) error {
+	if version == "" {
+		return keyError("delete", key, ErrConflict)
+	}
+
+	if err := checkVersion(tx, "delete", key, version); err != nil {
+		return err
+	}
+
+	return tx.Delete(key)
+}
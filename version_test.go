@@ -0,0 +1,50 @@
+package kv_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sdeoras/kv"
+)
+
+// testRewrite checks that a version goes stale once its key is written
+// again, even when the key is written back to the same value.
+func testRewrite(t *testing.T, db kv.KV) {
+	v := db.(kv.Versioned)
+
+	if err := db.Set("/a/b", []byte("val")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, version, err := v.GetWithVersion("/a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, val := range []string{"otherVal", "val"} {
+		if err := db.Set("/a/b", []byte(val)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, current, err := v.GetWithVersion("/a/b"); err != nil {
+		t.Fatal(err)
+	} else if current == version {
+		t.Fatal("expected a new version after rewriting the same value, got:", current)
+	}
+
+	if err := v.SetIfVersion("/a/b", []byte("new"), version); !errors.Is(err, kv.ErrConflict) {
+		t.Fatal("expected", kv.ErrConflict, "got:", err)
+	}
+}
+
+func TestMemKv_Rewrite(t *testing.T) {
+	testRewrite(t, kv.NewMemKv())
+}
+
+func TestBoltKv_Rewrite(t *testing.T) {
+	db, _, done := newBoltKv(t)
+	defer done()
+
+	testRewrite(t, db)
+}
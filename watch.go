@@ -0,0 +1,185 @@
+package kv
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+)
+
+// hub fans events out to in-process watchers. Backends record events within
+// a transaction and publish them once it commits, while holding the lock that
+// serializes writers, so that watchers see changes in commit order.
+type hub struct {
+	mu       sync.Mutex
+	watchers map[*watcher]struct{}
+	// done is closed once the backend is closed.
+	done   chan struct{}
+	closed bool
+}
+
+func newHub() *hub {
+	return &hub{watchers: make(map[*watcher]struct{}), done: make(chan struct{})}
+}
+
+// active reports whether anyone is watching, so that writers can skip
+// recording events otherwise.
+func (h *hub) active() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.watchers) > 0
+}
+
+// watch registers a watcher for keys under prefix. Its channel is closed
+// once ctx is done or the hub is closed.
+func (h *hub) watch(ctx context.Context, prefix []string) <-chan Event {
+	w := &watcher{prefix: prefix, signal: make(chan struct{}, 1)}
+	out := make(chan Event)
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(out)
+		return out
+	}
+	h.watchers[w] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		defer close(out)
+		defer func() {
+			h.mu.Lock()
+			delete(h.watchers, w)
+			h.mu.Unlock()
+		}()
+
+		w.run(ctx, h.done, out)
+	}()
+
+	return out
+}
+
+// publish hands events to every watcher whose prefix they fall under.
+func (h *hub) publish(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for w := range h.watchers {
+		w.push(events)
+	}
+}
+
+// close ends all watchers.
+func (h *hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.closed {
+		h.closed = true
+		close(h.done)
+	}
+}
+
+// watcher queues events for a single call to Watch. The queue is unbounded
+// so that writers never block on slow watchers.
+type watcher struct {
+	prefix []string
+	mu     sync.Mutex
+	queue  []Event
+	// signal is sent to without blocking whenever events are queued.
+	signal chan struct{}
+}
+
+// push queues events under the prefix of w, copying values so that watchers
+// cannot modify each other's events.
+func (w *watcher) push(events []Event) {
+	w.mu.Lock()
+	n := len(w.queue)
+	for _, e := range events {
+		if !hasPrefix(splitPath(e.Key), w.prefix) {
+			continue
+		}
+
+		if e.Value != nil {
+			e.Value = append([]byte{}, e.Value...)
+		}
+		w.queue = append(w.queue, e)
+	}
+	queued := len(w.queue) > n
+	w.mu.Unlock()
+
+	if queued {
+		select {
+		case w.signal <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run sends queued events to out until ctx or done is done.
+func (w *watcher) run(ctx context.Context, done <-chan struct{}, out chan<- Event) {
+	for {
+		w.mu.Lock()
+		queue := w.queue
+		w.queue = nil
+		w.mu.Unlock()
+
+		for _, e := range queue {
+			select {
+			case out <- e:
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			}
+		}
+
+		select {
+		case <-w.signal:
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+// hasPrefix reports whether keys lie under prefix segment by segment.
+func hasPrefix(keys, prefix []string) bool {
+	if len(keys) < len(prefix) {
+		return false
+	}
+
+	for i := range prefix {
+		if keys[i] != prefix[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// diffPairs returns events turning the pairs in prev into those in next,
+// for backends that watch by polling. Events are sorted by key.
+func diffPairs(prev, next map[string][]byte) []Event {
+	var events []Event
+	for k, v := range next {
+		if old, ok := prev[k]; !ok || !bytes.Equal(old, v) {
+			events = append(events, Event{Kind: EventPut, Key: k, Value: v})
+		}
+	}
+
+	for k := range prev {
+		if _, ok := next[k]; !ok {
+			events = append(events, Event{Kind: EventDelete, Key: k})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool { return lessKey(events[i].Key, events[j].Key) })
+	return events
+}